@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/frizinak/phodo/edit"
 	"github.com/frizinak/phodo/flags"
@@ -34,9 +37,20 @@ func handleEdit(c phodo.Conf, args []string) error {
 	if len(args) == 0 {
 		return errors.New("please specify a file to edit")
 	}
-	if err := parseAssignments(c, args[1:]); err != nil {
+	n := 1
+	for n < len(args) && !strings.Contains(args[n], "=") {
+		n++
+	}
+	if err := parseAssignments(c, args[n:]); err != nil {
+		return err
+	}
+	files, err := phodo.Images(args[:n])
+	if err != nil {
 		return err
 	}
+	if len(files) == 0 {
+		return errors.New("no images found")
+	}
 
 	c.DefaultPipelines = func() string {
 		return `
@@ -47,7 +61,118 @@ func handleEdit(c phodo.Conf, args []string) error {
 	}
 
 	defer edit.Destroy(true)
-	return phodo.Editor(context.Background(), c, args[0])
+	return phodo.Editor(context.Background(), c, files...)
+}
+
+func handleServe(c phodo.Conf, addr string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("please specify a file to serve")
+	}
+	if err := parseAssignments(c, args[1:]); err != nil {
+		return err
+	}
+
+	c.DefaultPipelines = func() string {
+		return `
+.main(
+    orientation()
+)
+`
+	}
+
+	return phodo.Serve(context.Background(), c, args[0], addr)
+}
+
+func historyScript(c phodo.Conf, args []string) (string, []phodo.Version, error) {
+	if len(args) == 0 {
+		return "", nil, errors.New("please specify an image or script")
+	}
+
+	script := args[0]
+	if filepath.Ext(script) != ".pho" {
+		var err error
+		script, err = phodo.SidecarPath(c, script)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	l, err := phodo.History(script)
+	if err == nil && len(l) == 0 {
+		err = fmt.Errorf("no history for '%s'", script)
+	}
+	return script, l, err
+}
+
+func historyVersion(l []phodo.Version, arg string) (phodo.Version, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(l) {
+		return phodo.Version{}, fmt.Errorf("'%s' is not a version between 1 and %d", arg, len(l))
+	}
+	return l[n-1], nil
+}
+
+func handleHistoryList(c phodo.Conf, args []string) error {
+	_, l, err := historyScript(c, args)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range l {
+		fmt.Printf("%4d  %s\n", i+1, v.Time.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+func handleHistoryDiff(c phodo.Conf, args []string) error {
+	script, l, err := historyScript(c, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("please specify a version")
+	}
+
+	v, err := historyVersion(l, args[1])
+	if err != nil {
+		return err
+	}
+	a, err := v.Read()
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	if len(args) > 2 {
+		v, err := historyVersion(l, args[2])
+		if err != nil {
+			return err
+		}
+		b, err = v.Read()
+	} else {
+		b, err = os.ReadFile(script)
+	}
+	if err != nil {
+		return err
+	}
+
+	return phodo.Diff(os.Stdout, a, b)
+}
+
+func handleHistoryRestore(c phodo.Conf, args []string) error {
+	script, l, err := historyScript(c, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("please specify a version")
+	}
+
+	v, err := historyVersion(l, args[1])
+	if err != nil {
+		return err
+	}
+	return phodo.Restore(script, v)
 }
 
 func handleDo(c phodo.Conf, args []string) error {
@@ -93,6 +218,15 @@ func handleFormat(c phodo.Conf, args []string) error {
 	return enc.Flush()
 }
 
+func handleContactSheet(c phodo.Conf, s phodo.ContactSheetConf, args []string) error {
+	if len(args) == 0 {
+		return errors.New("please provide an output file")
+	} else if len(args) == 1 {
+		return errors.New("please provide one or more input files")
+	}
+	return phodo.ContactSheet(context.Background(), c, s, args[0], args[1:])
+}
+
 func main() {
 	c := phodo.NewConf(os.Stderr, nil)
 
@@ -113,14 +247,31 @@ func main() {
 		set.StringVar(&c.Script, "s", "", "path to the script (default \"<input-file>.pho\")")
 	}
 
+	var addr string
+
+	var sheet phodo.ContactSheetConf
+	flagSheet := func(set *flag.FlagSet) {
+		set.IntVar(&sheet.Columns, "cols", 4, "number of columns")
+		set.IntVar(&sheet.CellWidth, "cell-width", 400, "cell width")
+		set.IntVar(&sheet.CellHeight, "cell-height", 300, "cell height")
+		set.IntVar(&sheet.Gap, "gap", 10, "gap between cells")
+		set.Func("caption", "caption below each image: none, filename or exif (default none)", func(v string) error {
+			sheet.Caption = phodo.Caption(v)
+			return nil
+		})
+	}
+
 	fr := flags.NewRoot(os.Stdout)
 	fr.Define(func(set *flag.FlagSet) func(io.Writer) {
 		return func(w io.Writer) {
 			fmt.Fprintln(w, "Commands:")
 			fmt.Fprintln(w, "  do")
 			fmt.Fprintln(w, "  edit")
+			fmt.Fprintln(w, "  serve")
 			fmt.Fprintln(w, "  script")
+			fmt.Fprintln(w, "  contact-sheet")
 			fmt.Fprintln(w, "  list")
+			fmt.Fprintln(w, "  history")
 			fmt.Fprintln(w, "  format")
 			fmt.Fprintln(w, "  version")
 		}
@@ -156,18 +307,83 @@ func main() {
 
 		return func(w io.Writer) {
 			fmt.Fprintln(w, "Show an image viewer that reflects the changes in the sidecar file")
-			fmt.Fprintln(w, "for the given image.")
+			fmt.Fprintln(w, "for the given image(s).")
+			fmt.Fprintln(w, "Large images are first rendered at window size and refined at")
+			fmt.Fprintln(w, "full resolution in the background.")
 			fmt.Fprintln(w, "")
-			fmt.Fprintln(w, "phodo edit [flags] <input-file> [var1=value1 .. varN=valueN]")
+			fmt.Fprintln(w, "phodo edit [flags] <input-file> [.. input-fileN] [var1=value1 .. varN=valueN]")
 			fmt.Fprintln(w, "  [flags]")
 			set.PrintDefaults()
-			fmt.Fprintln(w, "  <input-file>  (required) Path to the image.")
+			fmt.Fprintln(w, "  <input-file>  (required) Path to the image(s) or a directory of images.")
+			fmt.Fprintln(w, "                -c only edits the sidecar file of the first image.")
 			fmt.Fprintln(w, "  [var1=value1] (optional) Assign values to script variables.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "Viewer controls:")
+			fmt.Fprintln(w, "  q             Quit.")
+			fmt.Fprintln(w, "  n / p         Next / previous image.")
+			fmt.Fprintln(w, "  y             Copy the sidecar file to the next image, replacing")
+			fmt.Fprintln(w, "                its sidecar file, and show it. The replaced sidecar")
+			fmt.Fprintln(w, "                file is kept in its history.")
+			fmt.Fprintln(w, "  u             Restore the previous rendered version of the sidecar")
+			fmt.Fprintln(w, "                file, press again to step further back.")
+			fmt.Fprintln(w, "                See phodo history.")
+			fmt.Fprintln(w, "  r             Rerender without cache.")
+			fmt.Fprintln(w, "  b             Toggle showing the original image.")
+			fmt.Fprintln(w, "  s             Toggle a split view of the original (left) and")
+			fmt.Fprintln(w, "                the result (right), drag the divider to move it.")
+			fmt.Fprintln(w, "  g             Toggle a histogram of the result.")
+			fmt.Fprintln(w, "  j             Toggle highlighting clipped shadows (blue) and")
+			fmt.Fprintln(w, "                highlights (red) in the result.")
+			fmt.Fprintln(w, "  0 / 1 / 2     Fit to window / 100% / 200%.")
+			fmt.Fprintln(w, "  + / -         Zoom in / out.")
+			fmt.Fprintln(w, "  scroll        Zoom around the cursor.")
+			fmt.Fprintln(w, "  right drag    Pan (middle mouse button works as well).")
+			fmt.Fprintln(w, "  left click    Print the color of the pixel under the cursor.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "Tools, confirming writes the element to the end of the pipeline in")
+			fmt.Fprintln(w, "the script or updates the last element if it is of the same kind:")
+			fmt.Fprintln(w, "  c             Toggle crop, drag a rectangle.")
+			fmt.Fprintln(w, "  w             Toggle white-balance-spot, click a neutral spot.")
+			fmt.Fprintln(w, "  h             Toggle heal-spot, drag from the blemish to the")
+			fmt.Fprintln(w, "                source. Always appends.")
+			fmt.Fprintln(w, "  t             Toggle straighten, drag along a line that should be")
+			fmt.Fprintln(w, "                horizontal or vertical.")
+			fmt.Fprintln(w, "  [ / ]         Decrease / increase the spot size.")
+			fmt.Fprintln(w, "  enter         Confirm the selection.")
+			fmt.Fprintln(w, "  escape        Clear the selection or leave the tool.")
 		}
 	}).Handler(func(set *flags.Set, args []string) error {
 		return handleEdit(c, args)
 	})
 
+	fr.Add("serve").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagVerbose(set)
+		flagPipeline(set)
+		flagScript(set)
+		set.StringVar(&addr, "addr", "127.0.0.1:8080", "address to listen on")
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "Serve the result of the sidecar file for the given image over HTTP")
+			fmt.Fprintln(w, "and render again when it changes. Like edit but without a window.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "phodo serve [flags] <input-file> [var1=value1 .. varN=valueN]")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+			fmt.Fprintln(w, "  <input-file>  (required) Path to the image.")
+			fmt.Fprintln(w, "  [var1=value1] (optional) Assign values to script variables.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "Endpoints:")
+			fmt.Fprintln(w, "  GET  /                  Page that shows the result.")
+			fmt.Fprintln(w, "  GET  /image             Latest result as a jpeg.")
+			fmt.Fprintln(w, "  GET  /events            Server-sent 'render' events.")
+			fmt.Fprintln(w, "  GET  /pixel?x=X&y=Y     Color of the pixel at x,y as json.")
+			fmt.Fprintln(w, "  POST /refresh           Render again.")
+			fmt.Fprintln(w, "  POST /refresh-no-cache  Render again without cache.")
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		return handleServe(c, addr, args)
+	})
+
 	fr.Add("script").Define(func(set *flag.FlagSet) func(io.Writer) {
 		flagVerbose(set)
 		flagPipeline(set)
@@ -185,6 +401,25 @@ func main() {
 		return handleScript(c, args)
 	})
 
+	fr.Add("contact-sheet").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagVerbose(set)
+		flagPipeline(set)
+		flagSheet(set)
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "Run each image through its sidecar file and lay out the results")
+			fmt.Fprintln(w, "in a grid. Images without a sidecar file are only oriented.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "phodo contact-sheet [flags] <output-file> <input-file> [.. input-fileN]")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+			fmt.Fprintln(w, "  <output-file> (required) Path to the output image.")
+			fmt.Fprintln(w, "  <input-file>  (required) Path to the image(s).")
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		return handleContactSheet(c, sheet, args)
+	})
+
 	list := fr.Add("list").Define(func(set *flag.FlagSet) func(io.Writer) {
 		return func(w io.Writer) {
 			fmt.Fprintln(w, "phodo list <type>")
@@ -211,6 +446,68 @@ func main() {
 		})
 	}
 
+	history := fr.Add("history").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagScript(set)
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "List, diff or restore the versions of a sidecar file that were")
+			fmt.Fprintln(w, "rendered in edit mode.")
+			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, "phodo history [flags] <input-file>")
+			fmt.Fprintln(w, "phodo history list [flags] <input-file>")
+			fmt.Fprintln(w, "phodo history diff [flags] <input-file> <version> [version]")
+			fmt.Fprintln(w, "phodo history restore [flags] <input-file> <version>")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+			fmt.Fprintln(w, "  <input-file>  (required) Path to the image or the sidecar file.")
+			fmt.Fprintln(w, "  <version>     (required) Number of the version as listed.")
+			fmt.Fprintln(w, "                diff compares against the current file unless a")
+			fmt.Fprintln(w, "                second version is given.")
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		if len(args) == 0 {
+			set.Usage(1)
+			return nil
+		}
+		return handleHistoryList(c, args)
+	})
+
+	history.Add("list").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagScript(set)
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "phodo history list [flags] <input-file>")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		return handleHistoryList(c, args)
+	})
+
+	history.Add("diff").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagScript(set)
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "phodo history diff [flags] <input-file> <version> [version]")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		return handleHistoryDiff(c, args)
+	})
+
+	history.Add("restore").Define(func(set *flag.FlagSet) func(io.Writer) {
+		flagScript(set)
+
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "phodo history restore [flags] <input-file> <version>")
+			fmt.Fprintln(w, "  [flags]")
+			set.PrintDefaults()
+		}
+	}).Handler(func(set *flags.Set, args []string) error {
+		return handleHistoryRestore(c, args)
+	})
+
 	fr.Add("format").Define(func(set *flag.FlagSet) func(io.Writer) {
 		flagScript(set)
 
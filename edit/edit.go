@@ -1,6 +1,8 @@
 package edit
 
 import (
+	"image"
+
 	"github.com/frizinak/phodo/img48"
 	"github.com/go-gl/glfw/v3.3/glfw"
 )
@@ -11,13 +13,149 @@ type Config struct {
 	OnResize func(w, h int)
 }
 
-func (v *Viewer) Set(img *img48.Img) {
+// Keys without a character that are passed to OnKey.
+const (
+	KeyEnter  rune = '\n'
+	KeyEscape rune = 0x1b
+)
+
+type Tool byte
+
+const (
+	// ToolNone reports left clicks through OnClick.
+	ToolNone Tool = iota
+	// ToolRect selects a rectangle by dragging.
+	ToolRect
+	// ToolLine selects a line by dragging.
+	ToolLine
+	// ToolSpot selects a circle around the point where the button is
+	// released.
+	ToolSpot
+	// ToolClone selects a circle where the button is pressed and one where
+	// it is released.
+	ToolClone
+)
+
+// Selection is what was selected with a Tool in image coordinates. From and
+// To are inclusive and equal for ToolSpot.
+type Selection struct {
+	Tool     Tool
+	From, To image.Point
+	Radius   int
+}
+
+type CompareMode byte
+
+const (
+	// CompareNone shows the image passed to Set.
+	CompareNone CompareMode = iota
+	// CompareBefore shows the image passed to SetOriginal.
+	CompareBefore
+	// CompareSplit shows the original left and the image right of a
+	// draggable divider.
+	CompareSplit
+)
+
+func (v *Viewer) Set(img *img48.Img) { v.SetPreview(img, 1) }
+
+// SetPreview sets a version of the image that is scaled down by scale. It is
+// drawn at the size of the full resolution image so zoom, pan and
+// selections are unaffected.
+func (v *Viewer) SetPreview(img *img48.Img, scale float64) {
 	v.sem.Lock()
 	v.img = img
+	v.imgScale = scale
 	v.inval = true
 	v.sem.Unlock()
 }
 
+// SetHistogram sets an image that is drawn in the top right corner of the
+// window at its actual size, nil hides it.
+func (v *Viewer) SetHistogram(img *img48.Img) {
+	v.sem.Lock()
+	v.hist = img
+	v.histInval = true
+	v.sem.Unlock()
+}
+
+// SetClipping sets an image that is shown instead of the image passed to Set,
+// e.g.: a copy with clipped pixels highlighted. nil shows the image itself.
+// It should be of the same size.
+func (v *Viewer) SetClipping(img *img48.Img) {
+	v.sem.Lock()
+	v.clip = img
+	v.clipInval = true
+	v.sem.Unlock()
+}
+
+// SetBanner sets an image that is drawn in the bottom left corner of the
+// window at its actual size on top of everything else, e.g.: an error
+// message. nil hides it.
+func (v *Viewer) SetBanner(img *img48.Img) {
+	v.sem.Lock()
+	v.banner = img
+	v.bannerInval = true
+	v.sem.Unlock()
+}
+
+// SetTitle sets the window title.
+func (v *Viewer) SetTitle(title string) {
+	v.sem.Lock()
+	v.title = title
+	v.titleInval = true
+	v.sem.Unlock()
+}
+
+// SetOriginal sets the image to compare against.
+func (v *Viewer) SetOriginal(img *img48.Img) {
+	v.sem.Lock()
+	v.orig = img
+	v.origInval = true
+	v.sem.Unlock()
+}
+
+// SetCompare sets the comparison mode, it has no effect until an original
+// is set.
+func (v *Viewer) SetCompare(m CompareMode) {
+	v.sem.Lock()
+	if m == CompareSplit && v.split == 0 {
+		v.split = 0.5
+	}
+	v.compare = m
+	v.sem.Unlock()
+}
+
+func (v *Viewer) Compare() CompareMode {
+	v.sem.Lock()
+	defer v.sem.Unlock()
+	return v.compare
+}
+
+// SetTool changes what dragging with the left mouse button does and clears
+// the current selection. radius is the radius in image pixels of the circles
+// of ToolSpot and ToolClone.
+func (v *Viewer) SetTool(t Tool, radius int) {
+	v.sem.Lock()
+	v.sel = Selection{Tool: t, Radius: radius}
+	v.selected = false
+	v.sem.Unlock()
+}
+
+// SetRadius changes the radius of the current tool and selection.
+func (v *Viewer) SetRadius(radius int) {
+	v.sem.Lock()
+	v.sel.Radius = radius
+	v.sem.Unlock()
+}
+
+// Selection returns the current selection, ok is false if nothing is
+// selected.
+func (v *Viewer) Selection() (s Selection, ok bool) {
+	v.sem.Lock()
+	defer v.sem.Unlock()
+	return v.sel, v.selected
+}
+
 func (v *Viewer) Run(c Config, exit <-chan struct{}, spawned chan<- struct{}) error {
 	go func() {
 		<-exit
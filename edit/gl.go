@@ -116,9 +116,14 @@ in vec3 FragPos;
 
 uniform sampler2D texture1;
 uniform mat4 projection;
+uniform vec4 solid;
 
 void main()
 {
+    if (solid.a > 0.0) {
+        color = solid;
+        return;
+    }
     color = texture(texture1, TexCoord);
 }`
 	vertexShader, err := compileShader(vertexShaderSrc, gl.VERTEX_SHADER)
@@ -176,6 +181,19 @@ func initialize() (*glfw.Window, error) {
 	return window, nil
 }
 
+const (
+	zoomMax  = 32
+	zoomStep = 1.25
+
+	// dividerGrab is the distance in pixels from the split view divider
+	// within which it can be dragged.
+	dividerGrab = 6
+
+	// previewSlack is the difference in size in pixels between a preview
+	// and the next image within which zoom and pan are kept.
+	previewSlack = 2
+)
+
 type Viewer struct {
 	window    *glfw.Window
 	monitor   *glfw.Monitor
@@ -184,58 +202,258 @@ type Viewer struct {
 	sem   sync.Mutex
 	img   *img48.Img
 	inval bool
+	// imgScale is the factor by which img is scaled down, see SetPreview.
+	imgScale float64
+
+	orig      *img48.Img
+	origInval bool
+	compare   CompareMode
+	// split is the position of the divider in CompareSplit mode as a
+	// fraction of the window width.
+	split float64
+
+	sel      Selection
+	selected bool
+
+	hist, clip           *img48.Img
+	histInval, clipInval bool
+
+	banner      *img48.Img
+	bannerInval bool
+
+	title      string
+	titleInval bool
 
 	width, height int
 
 	proj mgl32.Mat4
 
 	cursor struct {
-		x, y float64
-		down bool
+		x, y    float64
+		down    bool
+		pan     bool
+		divider bool
+		// selecting is true while dragging a selection.
+		selecting bool
 	}
 
+	// pos describes where and at what zoom level the image is drawn.
 	pos struct {
-		x, y       int
+		x, y       float64
 		maxX, maxY int
-		scale      float64
+		// zoom is the number of screen pixels per image pixel.
+		zoom float64
+		// scale is the inverse of zoom, 0 if there is no image.
+		scale float64
+		// fit makes the image fit the window, zoom is ignored.
+		fit   bool
+		dirty bool
 	}
 
 	c Config
 }
 
 func (v *Viewer) onCursor(w *glfw.Window, x, y float64) {
+	if v.cursor.divider && v.width > 0 {
+		v.sem.Lock()
+		v.split = math.Max(0, math.Min(1, x/float64(v.width)))
+		v.sem.Unlock()
+	}
+	if v.cursor.pan {
+		v.pos.x += x - v.cursor.x
+		v.pos.y += y - v.cursor.y
+		v.pos.dirty = true
+	}
 	v.cursor.x, v.cursor.y = x, y
+	if v.cursor.selecting {
+		v.sem.Lock()
+		v.sel.To = v.imagePoint()
+		if v.sel.Tool == ToolSpot {
+			v.sel.From = v.sel.To
+		}
+		v.sem.Unlock()
+	}
 	v.reportCursor()
 }
 
 func (v *Viewer) onClick(w *glfw.Window, btn glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	switch btn {
+	case glfw.MouseButton2, glfw.MouseButton3:
+		v.cursor.pan = action == glfw.Press
+		return
+	}
+
 	v.cursor.down = btn == glfw.MouseButton1 && action == glfw.Press
+	v.cursor.divider = false
+	v.cursor.selecting = false
 	if btn != glfw.MouseButton1 || action != glfw.Press || v.pos.scale == 0 {
 		return
 	}
 
+	v.sem.Lock()
+	split := v.compare == CompareSplit
+	tool := v.sel.Tool
+	v.sem.Unlock()
+	if split && math.Abs(v.cursor.x-float64(v.divider())) <= dividerGrab {
+		v.cursor.down = false
+		v.cursor.divider = true
+		return
+	}
+
+	if tool != ToolNone {
+		v.cursor.down = false
+		v.cursor.selecting = true
+		p := v.imagePoint()
+		v.sem.Lock()
+		v.sel.From, v.sel.To = p, p
+		v.selected = true
+		v.sem.Unlock()
+		return
+	}
+
 	v.reportCursor()
 }
 
+// imagePoint returns the image pixel under the cursor, clamped to the image
+// bounds.
+func (v *Viewer) imagePoint() image.Point {
+	clamp := func(v float64, max int) int {
+		return int(math.Max(0, math.Min(float64(max-1), math.Floor(v))))
+	}
+	return image.Point{
+		clamp(v.pos.scale*(v.cursor.x-v.pos.x), v.pos.maxX),
+		clamp(v.pos.scale*(v.cursor.y-v.pos.y), v.pos.maxY),
+	}
+}
+
+func (v *Viewer) onScroll(w *glfw.Window, xoff, yoff float64) {
+	v.zoomAt(v.pos.zoom*math.Pow(zoomStep, yoff), v.cursor.x, v.cursor.y)
+}
+
+// zoomAt sets the zoom level while keeping the image pixel at screen
+// coordinates sx, sy in place.
+func (v *Viewer) zoomAt(zoom, sx, sy float64) {
+	if v.pos.scale == 0 {
+		return
+	}
+
+	min := v.fitZoom()
+	if min > 1 {
+		min = 1
+	}
+	zoom = math.Max(min, math.Min(zoomMax, zoom))
+
+	ix, iy := (sx-v.pos.x)/v.pos.zoom, (sy-v.pos.y)/v.pos.zoom
+	v.pos.x, v.pos.y = sx-ix*zoom, sy-iy*zoom
+	v.pos.zoom = zoom
+	v.pos.fit = false
+	v.pos.dirty = true
+}
+
+// divider returns the x position of the split view divider.
+func (v *Viewer) divider() int {
+	v.sem.Lock()
+	split := v.split
+	v.sem.Unlock()
+	return int(math.Round(split * float64(v.width)))
+}
+
+func (v *Viewer) fitZoom() float64 {
+	if v.pos.maxX == 0 || v.pos.maxY == 0 {
+		return 1
+	}
+
+	z := math.Min(
+		float64(v.width)/float64(v.pos.maxX),
+		float64(v.height)/float64(v.pos.maxY),
+	)
+	if z > 1 {
+		z = 1
+	}
+	return z
+}
+
+// layout positions the image in the window, centering it along axes where
+// it is smaller than the window and keeping the window filled otherwise.
+func (v *Viewer) layout() {
+	if v.pos.maxX == 0 || v.pos.maxY == 0 {
+		v.pos.scale = 0
+		return
+	}
+
+	if v.pos.fit {
+		v.pos.zoom = v.fitZoom()
+	}
+
+	clamp := func(p float64, size int, win int) float64 {
+		s := float64(size) * v.pos.zoom
+		w := float64(win)
+		if s <= w {
+			return math.Round((w - s) / 2)
+		}
+		return math.Round(math.Max(w-s, math.Min(0, p)))
+	}
+
+	v.pos.x = clamp(v.pos.x, v.pos.maxX, v.width)
+	v.pos.y = clamp(v.pos.y, v.pos.maxY, v.height)
+	v.pos.scale = 1 / v.pos.zoom
+}
+
 func (v *Viewer) reportCursor() {
-	if !v.cursor.down || v.pos.scale == 0 {
+	if !v.cursor.down || v.pos.scale == 0 || v.c.OnClick == nil {
 		return
 	}
 
-	rx := v.pos.scale * (v.cursor.x - float64(v.pos.x))
-	ry := v.pos.scale * (v.cursor.y - float64(v.pos.y))
-	x := int(math.Round(rx))
-	y := int(math.Round(ry))
-	if x > 0 && y > 0 && x <= v.pos.maxX && y < v.pos.maxY {
+	rx := v.pos.scale * (v.cursor.x - v.pos.x)
+	ry := v.pos.scale * (v.cursor.y - v.pos.y)
+	x := int(math.Floor(rx))
+	y := int(math.Floor(ry))
+	if x >= 0 && y >= 0 && x < v.pos.maxX && y < v.pos.maxY {
 		v.c.OnClick(x, y)
 	}
 }
 
 func (v *Viewer) onText(w *glfw.Window, char rune) {
-	v.c.OnKey(char)
+	cx, cy := float64(v.width)/2, float64(v.height)/2
+	switch char {
+	case '0':
+		v.pos.fit = true
+		v.pos.dirty = true
+		return
+	case '1', '2':
+		v.zoomAt(float64(char-'0'), cx, cy)
+		return
+	case '+', '=':
+		v.zoomAt(v.pos.zoom*zoomStep, cx, cy)
+		return
+	case '-':
+		v.zoomAt(v.pos.zoom/zoomStep, cx, cy)
+		return
+	}
+
+	if v.c.OnKey != nil {
+		v.c.OnKey(char)
+	}
+}
+
+func (v *Viewer) onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press || v.c.OnKey == nil {
+		return
+	}
+
+	switch key {
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		v.c.OnKey(KeyEnter)
+	case glfw.KeyEscape:
+		v.c.OnKey(KeyEscape)
+	}
 }
 
 func (v *Viewer) onResize(wnd *glfw.Window, width, height int) {
+	if !v.pos.fit {
+		v.pos.x += float64(width-v.width) / 2
+		v.pos.y += float64(height-v.height) / 2
+	}
 	v.width, v.height = width, height
 	gl.Viewport(0, 0, int32(width), int32(height))
 	v.proj = mgl32.Ortho2D(0, float32(width), float32(height), 0)
@@ -262,14 +480,14 @@ func (v *Viewer) run(spawned chan<- struct{}) error {
 		return err
 	}
 
+	v.width, v.height = 800, 800
+	v.pos.fit = true
 	v.window.SetFramebufferSizeCallback(v.onResize)
-	if v.c.OnKey != nil {
-		v.window.SetCharCallback(v.onText)
-	}
-	if v.c.OnClick != nil {
-		v.window.SetCursorPosCallback(v.onCursor)
-		v.window.SetMouseButtonCallback(v.onClick)
-	}
+	v.window.SetCharCallback(v.onText)
+	v.window.SetKeyCallback(v.onKey)
+	v.window.SetCursorPosCallback(v.onCursor)
+	v.window.SetMouseButtonCallback(v.onClick)
+	v.window.SetScrollCallback(v.onScroll)
 
 	program, err := newProgram()
 	if err != nil {
@@ -278,14 +496,14 @@ func (v *Viewer) run(spawned chan<- struct{}) error {
 	gl.UseProgram(program)
 	gl.Enable(gl.TEXTURE_2D)
 
-	var tex uint32 = 0
+	var tex, origTex, histTex, clipTex, bannerTex uint32
 	model := mgl32.Ident4()
 
 	lastProjection := mgl32.Ident4()
-	var lastTex uint32 = 0
 
 	modelUniform := gl.GetUniformLocation(program, gl.Str("model\x00"))
 	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	solidUniform := gl.GetUniformLocation(program, gl.Str("solid\x00"))
 
 	var ebo uint32
 	indices := []uint32{0, 1, 3, 1, 2, 3}
@@ -293,125 +511,284 @@ func (v *Viewer) run(spawned chan<- struct{}) error {
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ebo)
 	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, 6*fs, gl.Ptr(indices), gl.STATIC_DRAW)
 
+	// A unit quad, the model matrix scales it to the size of what is drawn.
 	var vao, vbo uint32
-	var obounds image.Point
-	updateVAO := func(bounds image.Point) {
-		first := vao == 0
-		if !first && obounds == bounds {
-			return
-		}
-		obounds = bounds
+	{
 		d := points{}
-		buf(&d, 0, 0, float32(bounds.X), float32(bounds.Y))
-		if first {
-			gl.GenVertexArrays(1, &vao)
-			gl.GenBuffers(1, &vbo)
-			gl.BindVertexArray(vao)
-			gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ebo)
-		}
-
+		buf(&d, 0, 0, 1, 1)
+		gl.GenVertexArrays(1, &vao)
+		gl.GenBuffers(1, &vbo)
+		gl.BindVertexArray(vao)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ebo)
 		gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-		gl.BufferData(gl.ARRAY_BUFFER, stride*vertices*fs, gl.Ptr(&d[0]), gl.DYNAMIC_DRAW)
-		if !first {
-			gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-			return
-		}
-
+		gl.BufferData(gl.ARRAY_BUFFER, stride*vertices*fs, gl.Ptr(&d[0]), gl.STATIC_DRAW)
 		gl.EnableVertexAttribArray(0)
 		gl.VertexAttribPointer(0, 2, gl.FLOAT, false, stride*fs, gl.PtrOffset(0))
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, stride*fs, gl.PtrOffset(2*fs))
-
 		gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-		gl.BindVertexArray(0)
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
 	}
 
-	update := func() (image.Point, error) {
+	type state struct {
+		bounds, orig, hist, banner image.Point
+		compare                    CompareMode
+		clip                       bool
+	}
+
+	texture := func(tex *uint32, img *img48.Img) error {
+		if *tex != 0 {
+			if err := releaseTexture(*tex); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		*tex, err = imgTexture(img)
+		return err
+	}
+
+	// overlay updates the texture of an image that can be unset.
+	overlay := func(tex *uint32, img *img48.Img, inval *bool) error {
+		if !*inval {
+			return nil
+		}
+		*inval = false
+		if img != nil {
+			return texture(tex, img)
+		}
+		if *tex == 0 {
+			return nil
+		}
+		err := releaseTexture(*tex)
+		*tex = 0
+		return err
+	}
+
+	update := func() (state, error) {
 		v.sem.Lock()
 		defer v.sem.Unlock()
-		var bounds image.Point
+		st := state{compare: v.compare}
+		if v.hist != nil {
+			st.hist = image.Point{v.hist.Rect.Dx(), v.hist.Rect.Dy()}
+		}
+		if err := overlay(&histTex, v.hist, &v.histInval); err != nil {
+			return st, err
+		}
+		if err := overlay(&clipTex, v.clip, &v.clipInval); err != nil {
+			return st, err
+		}
+		st.clip = v.clip != nil
+		if v.banner != nil {
+			st.banner = image.Point{v.banner.Rect.Dx(), v.banner.Rect.Dy()}
+		}
+		if err := overlay(&bannerTex, v.banner, &v.bannerInval); err != nil {
+			return st, err
+		}
+		if v.titleInval {
+			v.titleInval = false
+			v.window.SetTitle(v.title)
+		}
 		if v.img != nil {
-			bounds = image.Point{v.img.Rect.Dx(), v.img.Rect.Dy()}
-			if bounds.X != 0 && bounds.Y != 0 {
-				rat := float64(bounds.X) / float64(bounds.Y)
-				rw, rh := v.width, v.height
-				if rw > bounds.X {
-					rw = bounds.X
-				}
-				if rh > bounds.Y {
-					rh = bounds.Y
-				}
-				bounds.X, bounds.Y = rw, int(float64(rw)/rat)
-				if float64(rh)/float64(bounds.Y) < float64(rw)/float64(bounds.X) {
-					bounds.X, bounds.Y = int(float64(rh)*rat), rh
-				}
+			st.bounds = image.Point{
+				int(math.Round(float64(v.img.Rect.Dx()) / v.imgScale)),
+				int(math.Round(float64(v.img.Rect.Dy()) / v.imgScale)),
 			}
+		}
+		if v.orig != nil {
+			st.orig = image.Point{v.orig.Rect.Dx(), v.orig.Rect.Dy()}
+		}
 
-			updateVAO(bounds)
+		if v.origInval && v.orig != nil {
+			v.origInval = false
+			if err := texture(&origTex, v.orig); err != nil {
+				return st, err
+			}
 		}
 
 		if !v.inval || v.img == nil {
-			return bounds, nil
+			return st, nil
 		}
 
 		v.inval = false
-		if tex != 0 {
-			if err := releaseTexture(tex); err != nil {
-				return bounds, err
+		return st, texture(&tex, v.img)
+	}
+
+	draw := func(tex uint32, x, y, w, h float32) {
+		model = mgl32.Translate3D(x, y, 0).Mul4(mgl32.Scale3D(w, h, 1))
+		gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+		gl.BindTexture(gl.TEXTURE_2D, tex)
+		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	}
+
+	drawImage := func(tex uint32, size image.Point) {
+		z := float32(v.pos.zoom)
+		draw(tex, float32(v.pos.x), float32(v.pos.y), float32(size.X)*z, float32(size.Y)*z)
+	}
+
+	// line draws a line of the given width between two points in window
+	// coordinates.
+	line := func(x0, y0, x1, y1, width float32) {
+		dx, dy := float64(x1-x0), float64(y1-y0)
+		l := float32(math.Hypot(dx, dy))
+		model = mgl32.Translate3D(x0, y0, 0).
+			Mul4(mgl32.HomogRotate3DZ(float32(math.Atan2(dy, dx)))).
+			Mul4(mgl32.Translate3D(-width/2, -width/2, 0)).
+			Mul4(mgl32.Scale3D(l+width, width, 1))
+		gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	}
+
+	// outline returns the line segments that outline the selection.
+	outline := func(s Selection) [][4]float32 {
+		z := float32(v.pos.zoom)
+		x0, y0 := float32(v.pos.x), float32(v.pos.y)
+		center := func(p image.Point) (float32, float32) {
+			return x0 + (float32(p.X)+0.5)*z, y0 + (float32(p.Y)+0.5)*z
+		}
+		circle := func(p image.Point, l [][4]float32) [][4]float32 {
+			const n = 64
+			cx, cy := center(p)
+			r := float64(s.Radius) * v.pos.zoom
+			px, py := cx+float32(r), cy
+			for i := 1; i <= n; i++ {
+				a := 2 * math.Pi * float64(i) / n
+				x, y := cx+float32(r*math.Cos(a)), cy+float32(r*math.Sin(a))
+				l = append(l, [4]float32{px, py, x, y})
+				px, py = x, y
 			}
+			return l
 		}
 
-		var err error
-		tex, err = imgTexture(v.img)
-		if err != nil {
-			return bounds, err
+		l := make([][4]float32, 0, 4)
+		switch s.Tool {
+		case ToolRect:
+			r := image.Rectangle{s.From, s.To}.Canon()
+			ax, ay := x0+float32(r.Min.X)*z, y0+float32(r.Min.Y)*z
+			bx, by := x0+float32(r.Max.X+1)*z, y0+float32(r.Max.Y+1)*z
+			l = append(
+				l,
+				[4]float32{ax, ay, bx, ay},
+				[4]float32{bx, ay, bx, by},
+				[4]float32{bx, by, ax, by},
+				[4]float32{ax, by, ax, ay},
+			)
+		case ToolLine:
+			ax, ay := center(s.From)
+			bx, by := center(s.To)
+			l = append(l, [4]float32{ax, ay, bx, by})
+		case ToolSpot:
+			l = circle(s.To, l)
+		case ToolClone:
+			ax, ay := center(s.From)
+			bx, by := center(s.To)
+			l = append(l, [4]float32{ax, ay, bx, by})
+			l = circle(s.From, l)
+			l = circle(s.To, l)
 		}
 
-		return bounds, err
+		return l
 	}
 
 	var lastBounds image.Point
+	drawResult := func(st state) {
+		if st.bounds != lastBounds {
+			// A differently sized image invalidates the zoom and pan. The
+			// size of previews is estimated and may be off by a pixel.
+			d := st.bounds.Sub(lastBounds)
+			if d.X < -previewSlack || d.X > previewSlack || d.Y < -previewSlack || d.Y > previewSlack {
+				v.pos.fit = true
+			}
+			lastBounds = st.bounds
+			v.pos.maxX, v.pos.maxY = st.bounds.X, st.bounds.Y
+			v.pos.dirty = true
+		}
+
+		if v.pos.dirty {
+			v.pos.dirty = false
+			v.layout()
+		}
+
+		if origTex == 0 {
+			st.compare = CompareNone
+		}
+
+		result := tex
+		if st.clip && clipTex != 0 {
+			result = clipTex
+		}
+
+		switch st.compare {
+		case CompareBefore:
+			drawImage(origTex, st.orig)
+		case CompareSplit:
+			// The original is shown left of the divider, both images are
+			// aligned at their top left corner.
+			div := v.divider()
+			gl.Enable(gl.SCISSOR_TEST)
+			gl.Scissor(0, 0, int32(div), int32(v.height))
+			drawImage(origTex, st.orig)
+			gl.Scissor(int32(div), 0, int32(v.width-div), int32(v.height))
+			drawImage(result, st.bounds)
+			gl.Disable(gl.SCISSOR_TEST)
+
+			gl.Uniform4f(solidUniform, 1, 1, 1, 1)
+			draw(0, float32(div-1), 0, 2, float32(v.height))
+			gl.Uniform4f(solidUniform, 0, 0, 0, 0)
+		default:
+			drawImage(result, st.bounds)
+		}
+	}
+
 	frame := func() error {
-		bounds, err := update()
+		st, err := update()
 		if err != nil {
 			return err
 		}
-		if tex == 0 {
-			return nil
-		}
-		recenter := false
-		if tex != lastTex {
-			lastTex = tex
-			gl.BindTexture(gl.TEXTURE_2D, tex)
-			gl.BindVertexArray(vao)
-		}
+
+		gl.BindVertexArray(vao)
 
 		if v.proj != lastProjection {
 			gl.UniformMatrix4fv(projectionUniform, 1, false, &v.proj[0])
 			lastProjection = v.proj
-			recenter = true
+			v.pos.dirty = true
+		}
+
+		// The histogram and banner are drawn without a result as well, e.g.:
+		// to show why the first render failed.
+		if tex != 0 {
+			drawResult(st)
 		}
 
-		if bounds != lastBounds {
-			lastBounds = bounds
-			recenter = true
+		if histTex != 0 {
+			const margin = 8
+			x := float32(v.width - st.hist.X - margin)
+			w, h := float32(st.hist.X), float32(st.hist.Y)
+			gl.Uniform4f(solidUniform, 0.5, 0.5, 0.5, 1)
+			draw(0, x-1, margin-1, w+2, h+2)
+			gl.Uniform4f(solidUniform, 0, 0, 0, 0)
+			draw(histTex, x, margin, w, h)
 		}
 
-		if recenter {
-			v.pos.x = v.width/2 - lastBounds.X/2
-			v.pos.y = v.height/2 - lastBounds.Y/2
-			v.pos.scale = 0
-			if bounds.X != 0 && v.img != nil {
-				v.pos.maxX = v.img.Rect.Dx()
-				v.pos.maxY = v.img.Rect.Dy()
-				v.pos.scale = float64(v.pos.maxX) / float64(bounds.X)
+		if bannerTex != 0 {
+			const margin = 8
+			w, h := float32(st.banner.X), float32(st.banner.Y)
+			draw(bannerTex, margin, float32(v.height)-h-margin, w, h)
+		}
+
+		if sel, ok := v.Selection(); ok && tex != 0 {
+			// A dark outline keeps the selection visible on light images.
+			l := outline(sel)
+			gl.Uniform4f(solidUniform, 0, 0, 0, 1)
+			for _, s := range l {
+				line(s[0], s[1], s[2], s[3], 3)
 			}
-			model = mgl32.Translate3D(float32(v.pos.x), float32(v.pos.y), 0)
-			gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+			gl.Uniform4f(solidUniform, 1, 1, 1, 1)
+			for _, s := range l {
+				line(s[0], s[1], s[2], s[3], 1)
+			}
+			gl.Uniform4f(solidUniform, 0, 0, 0, 0)
 		}
 
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
 		return nil
 	}
 
@@ -25,7 +25,7 @@ const (
 	w_h   = int64(4)
 )
 
-func head(r io.Reader) (w, h int, exif []byte, err error) {
+func head(r io.Reader) (w, h int, exif, res []byte, err error) {
 	buf := make([]byte, w_sig+w_exf+w_res+w_w+w_h)
 	_, err = io.ReadFull(r, buf)
 	if err != nil {
@@ -35,6 +35,7 @@ func head(r io.Reader) (w, h int, exif []byte, err error) {
 	w = int(binary.LittleEndian.Uint32(buf[w_sig+w_exf+w_res:]))
 	h = int(binary.LittleEndian.Uint32(buf[w_sig+w_exf+w_res+w_w:]))
 	exif = buf[w_sig : w_sig+w_exf]
+	res = buf[w_sig+w_exf : w_sig+w_exf+w_res]
 
 	return
 }
@@ -42,7 +43,7 @@ func head(r io.Reader) (w, h int, exif []byte, err error) {
 func Decode(r io.Reader) (image.Image, error) { return Decode48(r) }
 
 func Decode48(r io.Reader) (*Img, error) {
-	w, h, exifHeader, err := head(r)
+	w, h, exifHeader, res, err := head(r)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +70,17 @@ func Decode48(r io.Reader) (*Img, error) {
 		}
 	}
 
+	if res[0]&flagAlpha != 0 {
+		b := make([]byte, 2*w*h)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		img.Alpha = make([]uint16, w*h)
+		for i := range img.Alpha {
+			img.Alpha[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		}
+	}
+
 	if exifHeader[0] != 0 {
 		ex, err := exif.ReadMemory(r, exifHeader)
 		if err == nil {
@@ -80,13 +92,17 @@ func Decode48(r io.Reader) (*Img, error) {
 }
 
 func DecodeConfig(r io.Reader) (image.Config, error) {
-	w, h, _, err := head(r)
+	w, h, _, res, err := head(r)
 	if err != nil {
 		return image.Config{}, err
 	}
+	var img Img
+	if res[0]&flagAlpha != 0 {
+		img.Alpha = []uint16{}
+	}
 	return image.Config{
 		Width:      int(w),
 		Height:     int(h),
-		ColorModel: Img{}.ColorModel(),
+		ColorModel: img.ColorModel(),
 	}, nil
 }
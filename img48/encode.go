@@ -12,11 +12,21 @@ const imgCacheSig = "i489"
 
 var reserved [64]byte // e.g. compression flags
 
+const (
+	flagAlpha = 1 << iota
+)
+
 func Encode(w io.Writer, img *Img) error {
 	width, height := img.Rect.Dx(), img.Rect.Dy()
 	ww := bufio.NewWriterSize(w, 1024*50)
 	buf := make([]byte, 8)
 	pix := make([]byte, width*height*3*2)
+	var alpha []byte
+	res := reserved
+	if img.Alpha != nil {
+		alpha = make([]byte, width*height*2)
+		res[0] |= flagAlpha
+	}
 
 	var written uint32
 	wr := func(d []byte) {
@@ -25,14 +35,14 @@ func Encode(w io.Writer, img *Img) error {
 	}
 
 	wr([]byte(imgCacheSig))
-	exw := exif.NewWriter(ww, img.Exif, uint32(4+4+len(reserved)+len(pix)))
+	exw := exif.NewWriter(ww, img.Exif, uint32(4+4+len(res)+len(pix)+len(alpha)))
 	if _, err := exw.WriteHeader(); err != nil {
 		return err
 	}
 
 	binary.LittleEndian.PutUint32(buf[0:], uint32(width))
 	binary.LittleEndian.PutUint32(buf[4:], uint32(height))
-	wr(reserved[:])
+	wr(res[:])
 	wr(buf)
 
 	do := 0
@@ -54,6 +64,19 @@ func Encode(w io.Writer, img *Img) error {
 
 	wr(pix)
 
+	if alpha != nil {
+		do = 0
+		as := img.Stride / 3
+		for y := 0; y < height; y++ {
+			for _, a := range img.Alpha[y*as : y*as+width] {
+				alpha[do+0] = uint8(a >> 8)
+				alpha[do+1] = uint8(a)
+				do += 2
+			}
+		}
+		wr(alpha)
+	}
+
 	if _, err := exw.WriteBody(); err != nil {
 		return err
 	}
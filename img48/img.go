@@ -19,6 +19,12 @@ type Img struct {
 	Stride int
 	Rect   image.Rectangle
 	Pix    []uint16
+
+	// Alpha is an optional non-premultiplied alpha plane with one sample per
+	// pixel, laid out like Pix but with a third of its stride. i.e.: the
+	// alpha of the pixel at Pix[o] is Alpha[o/3].
+	// A nil Alpha means the image is fully opaque.
+	Alpha []uint16
 }
 
 type Color struct {
@@ -38,14 +44,27 @@ func (i *Img) Set(x, y int, c color.Color) {
 		return
 	}
 	o := (y-i.Rect.Min.Y)*i.Stride + (x-i.Rect.Min.X)*3
-	r, g, b, _ := c.RGBA()
 	s := i.Pix[o : o+3 : o+3]
+	if i.Alpha != nil {
+		n := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		s[0], s[1], s[2] = n.R, n.G, n.B
+		i.Alpha[o/3] = n.A
+		return
+	}
+
+	r, g, b, _ := c.RGBA()
 	s[0] = uint16(r)
 	s[1] = uint16(g)
 	s[2] = uint16(b)
 }
 
-func (i Img) ColorModel() color.Model  { return color.RGBA64Model }
+func (i Img) ColorModel() color.Model {
+	if i.Alpha != nil {
+		return color.NRGBA64Model
+	}
+	return color.RGBA64Model
+}
+
 func (i *Img) Bounds() image.Rectangle { return i.Rect }
 func (i *Img) At(x, y int) color.Color {
 	if !(image.Point{x, y}.In(i.Rect)) {
@@ -53,9 +72,30 @@ func (i *Img) At(x, y int) color.Color {
 	}
 	o := (y-i.Rect.Min.Y)*i.Stride + (x-i.Rect.Min.X)*3
 	pix := i.Pix[o : o+3 : o+3]
+	if i.Alpha != nil {
+		return color.NRGBA64{pix[0], pix[1], pix[2], i.Alpha[o/3]}
+	}
 	return Color{pix[0], pix[1], pix[2]}
 }
 
+// Opaque reports whether every pixel in the image is fully opaque.
+func (i *Img) Opaque() bool {
+	if i.Alpha == nil {
+		return true
+	}
+
+	w, as := i.Rect.Dx(), i.Stride/3
+	for y := 0; y < i.Rect.Dy(); y++ {
+		for _, a := range i.Alpha[y*as : y*as+w] {
+			if a != 1<<16-1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 func (i *Img) SubImage(r image.Rectangle) image.Image {
 	r = r.Intersect(i.Rect)
 	if r.Empty() {
@@ -63,11 +103,17 @@ func (i *Img) SubImage(r image.Rectangle) image.Image {
 	}
 
 	o := (r.Min.Y-i.Rect.Min.Y)*i.Stride + (r.Min.X-i.Rect.Min.X)*3
+	var alpha []uint16
+	if i.Alpha != nil {
+		alpha = i.Alpha[o/3:]
+	}
+
 	return &Img{
 		Exif:   i.Exif,
 		Pix:    i.Pix[o:],
 		Stride: i.Stride,
 		Rect:   r,
+		Alpha:  alpha,
 	}
 }
 
@@ -112,6 +158,13 @@ func New(r image.Rectangle, ex *exif.Exif) *Img {
 	return mk(r, make([]uint16, 3*r.Dx()*r.Dy()), ex)
 }
 
+// NewAlpha creates a new, fully transparent image.
+func NewAlpha(r image.Rectangle, ex *exif.Exif) *Img {
+	img := New(r, ex)
+	img.Alpha = make([]uint16, r.Dx()*r.Dy())
+	return img
+}
+
 func mk(r image.Rectangle, b []uint16, ex *exif.Exif) *Img {
 	if ex == nil {
 		ex = exif.New()
@@ -145,16 +198,23 @@ func NewFrom8(buf []uint8, width, height int, ex *exif.Exif) (*Img, error) {
 		return mk(r, nb, ex), nil
 	case 4:
 		nb := make([]uint16, len(buf)/4*3)
+		alpha := make([]uint16, len(buf)/4)
+		opaque := true
 		for n := 0; n < len(buf); n += 4 {
 			i := n * 3 / 4
-			if buf[n+3] == 0 {
-				continue
+			alpha[n/4] = uint16(buf[n+3])<<8 | uint16(buf[n+3])
+			if buf[n+3] != 1<<8-1 {
+				opaque = false
 			}
 			nb[i+0] = uint16(buf[n+0]) << 8
 			nb[i+1] = uint16(buf[n+1]) << 8
 			nb[i+2] = uint16(buf[n+2]) << 8
 		}
-		return mk(r, nb, ex), nil
+		img := mk(r, nb, ex)
+		if !opaque {
+			img.Alpha = alpha
+		}
+		return img, nil
 	}
 
 	return nil, errors.New("invalid slice length / amount of channels")
@@ -170,15 +230,21 @@ func NewFrom16(buf []uint16, width, height int, ex *exif.Exif) (*Img, error) {
 		return mk(r, buf, ex), nil
 	case 4:
 		nb := make([]uint16, len(buf)/4*3)
+		alpha := make([]uint16, len(buf)/4)
+		opaque := true
 		for n := 0; n < len(buf); n += 4 {
 			i := n * 3 / 4
-			if buf[n+3] == 0 {
-				continue
+			alpha[n/4] = buf[n+3]
+			if buf[n+3] != 1<<16-1 {
+				opaque = false
 			}
-
 			copy(nb[i:i+3:i+3], buf[n:n+3:n+3])
 		}
-		return mk(r, nb, ex), nil
+		img := mk(r, nb, ex)
+		if !opaque {
+			img.Alpha = alpha
+		}
+		return img, nil
 	}
 
 	return nil, errors.New("invalid slice length / amount of channels")
@@ -190,6 +256,9 @@ func Normalize(i image.Image) *Img {
 	}
 
 	img := New(i.Bounds(), nil)
+	if o, ok := i.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		img.Alpha = make([]uint16, img.Rect.Dx()*img.Rect.Dy())
+	}
 
 	// Fast path
 	switch v := i.(type) {
@@ -219,7 +288,45 @@ func Normalize(i image.Image) *Img {
 		return img
 	}
 
-	panic("incompatible image")
+	iCopy(img, i)
+	return img
+}
+
+// iCopy is the slow path for image types without a specialized copy,
+// e.g.: *image.Paletted.
+func iCopy(dst *Img, src image.Image) {
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		o_ := (y - b.Min.Y) * dst.Stride
+		for x := b.Min.X; x < b.Max.X; x++ {
+			o := o_ + (x-b.Min.X)*3
+			c := color.NRGBA64Model.Convert(src.At(x, y)).(color.NRGBA64)
+			if c.A != 1<<16-1 && dst.Alpha == nil {
+				dst.Alpha = make([]uint16, dst.Rect.Dx()*dst.Rect.Dy())
+				for i := range dst.Alpha[:o/3] {
+					dst.Alpha[i] = 1<<16 - 1
+				}
+			}
+
+			pix := dst.Pix[o : o+3 : o+3]
+			pix[0], pix[1], pix[2] = c.R, c.G, c.B
+			if dst.Alpha != nil {
+				dst.Alpha[o/3] = c.A
+			}
+		}
+	}
+}
+
+// unpremul un-premultiplies the 16-bit color component v by alpha a.
+func unpremul(v, a uint32) uint16 {
+	if a == 0 {
+		return 0
+	}
+	v = v * 0xffff / a
+	if v > 0xffff {
+		return 0xffff
+	}
+	return uint16(v)
 }
 
 func iYCbCrCopy(dst *Img, src *image.YCbCr) {
@@ -284,9 +391,17 @@ func iRGBACopy(dst *Img, src *image.RGBA) {
 			rx := x - dst.Rect.Min.X
 			o := o_ + rx*3
 			co := co_ + rx*4
-			spix := src.Pix[co : co+3 : co+3]
+			spix := src.Pix[co : co+4 : co+4]
 			r, g, b := spix[0], spix[1], spix[2]
 			pix := dst.Pix[o : o+3 : o+3]
+			if dst.Alpha != nil {
+				a := uint32(spix[3]) * 0x101
+				dst.Alpha[o/3] = uint16(a)
+				pix[0] = unpremul(uint32(r)*0x101, a)
+				pix[1] = unpremul(uint32(g)*0x101, a)
+				pix[2] = unpremul(uint32(b)*0x101, a)
+				continue
+			}
 			pix[0] = uint16(r) << 8
 			pix[1] = uint16(g) << 8
 			pix[2] = uint16(b) << 8
@@ -304,6 +419,14 @@ func iNRGBACopy(dst *Img, src *image.NRGBA) {
 			o := o_ + rx*3
 			co := co_ + rx*4
 			a := src.Pix[co+3]
+			if dst.Alpha != nil {
+				dst.Alpha[o/3] = uint16(a) * 0x101
+				pix := dst.Pix[o : o+3 : o+3]
+				pix[0] = uint16(src.Pix[co+0]) * 0x101
+				pix[1] = uint16(src.Pix[co+1]) * 0x101
+				pix[2] = uint16(src.Pix[co+2]) * 0x101
+				continue
+			}
 			aa := uint32(a)
 			r := uint16(aa * (uint32(src.Pix[co+0]) << 8) / 0xff)
 			g := uint16(aa * (uint32(src.Pix[co+1]) << 8) / 0xff)
@@ -326,7 +449,15 @@ func iRGBA64Copy(dst *Img, src *image.RGBA64) {
 			o := o_ + rx*3
 			co := co_ + rx*8
 			pix := dst.Pix[o : o+3 : o+3]
-			spix := src.Pix[co : co+6 : co+6]
+			spix := src.Pix[co : co+8 : co+8]
+			if dst.Alpha != nil {
+				a := uint32(spix[6])<<8 | uint32(spix[7])
+				dst.Alpha[o/3] = uint16(a)
+				pix[0] = unpremul(uint32(spix[0])<<8|uint32(spix[1]), a)
+				pix[1] = unpremul(uint32(spix[2])<<8|uint32(spix[3]), a)
+				pix[2] = unpremul(uint32(spix[4])<<8|uint32(spix[5]), a)
+				continue
+			}
 			pix[0] = uint16(spix[0])<<8 | uint16(spix[1])
 			pix[1] = uint16(spix[2])<<8 | uint16(spix[3])
 			pix[2] = uint16(spix[4])<<8 | uint16(spix[5])
@@ -345,6 +476,14 @@ func iNRGBA64Copy(dst *Img, src *image.NRGBA64) {
 			co := co_ + rx*8
 			spix := src.Pix[co : co+8 : co+8]
 			a := uint32(spix[6])<<8 | uint32(spix[7])
+			if dst.Alpha != nil {
+				dst.Alpha[o/3] = uint16(a)
+				pix := dst.Pix[o : o+3 : o+3]
+				pix[0] = uint16(spix[0])<<8 | uint16(spix[1])
+				pix[1] = uint16(spix[2])<<8 | uint16(spix[3])
+				pix[2] = uint16(spix[4])<<8 | uint16(spix[5])
+				continue
+			}
 			r := uint16(a * (uint32(spix[0])<<8 | uint32(spix[1])) / 0xffff)
 			g := uint16(a * (uint32(spix[2])<<8 | uint32(spix[3])) / 0xffff)
 			b := uint16(a * (uint32(spix[4])<<8 | uint32(spix[5])) / 0xffff)
@@ -103,7 +103,12 @@ func (w *jwriter) Write(b []byte) (n int, err error) {
 			r(length)
 		}
 
-		return len(b), nil
+		if w.startOffset == 0 {
+			return len(b), nil
+		}
+		// The headers are complete, b is already part of buf which is
+		// written below.
+		n, b = len(b), nil
 	}
 
 	if w.app1.offset != 0 {
@@ -151,6 +156,10 @@ func (w *jwriter) Write(b []byte) (n int, err error) {
 		w.buf = w.buf[:0]
 	}
 
+	if len(b) == 0 {
+		return n, nil
+	}
+
 	n, err = w.w.Write(b)
 	return
 }
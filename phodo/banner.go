@@ -0,0 +1,58 @@
+package phodo
+
+import (
+	"image"
+	"sync"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline/element/core"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	bannerSize    = 14
+	bannerPadding = 8
+)
+
+var bannerFont struct {
+	once sync.Once
+	fnt  *sfnt.Font
+	err  error
+}
+
+// errorBanner renders err as white text on red that is at most maxWidth
+// pixels wide.
+func errorBanner(err error, maxWidth int) (*img48.Img, error) {
+	bannerFont.once.Do(func() {
+		bannerFont.fnt, bannerFont.err = core.FontLoad(goregular.TTF)
+	})
+	if bannerFont.err != nil {
+		return nil, bannerFont.err
+	}
+
+	msg := err.Error()
+	tw := maxWidth - 2*bannerPadding
+	if tw < 1 {
+		tw = 1
+	}
+	w, h, terr := core.TextMeasure(bannerFont.fnt, bannerSize, 1, msg, tw)
+	if terr != nil {
+		return nil, terr
+	}
+
+	img := img48.New(image.Rect(0, 0, w+2*bannerPadding, h+2*bannerPadding), nil)
+	bg := []uint16{0xa000, 0x1000, 0x1000}
+	for o := 0; o < len(img.Pix); o += 3 {
+		copy(img.Pix[o:o+3], bg)
+	}
+
+	return img, core.TextBox(img, core.TextBoxOptions{
+		Rect:        image.Rect(bannerPadding, bannerPadding, bannerPadding+w, bannerPadding+h),
+		Size:        bannerSize,
+		LineSpacing: 1,
+		Text:        msg,
+		Font:        bannerFont.fnt,
+		Color:       core.SimpleColor{R: 1<<16 - 1, G: 1<<16 - 1, B: 1<<16 - 1},
+	})
+}
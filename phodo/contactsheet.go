@@ -0,0 +1,203 @@
+package phodo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/frizinak/phodo/exif"
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+type Caption string
+
+const (
+	CaptionNone     Caption = "none"
+	CaptionFilename Caption = "filename"
+	CaptionExif     Caption = "exif"
+)
+
+type ContactSheetConf struct {
+	Columns    int
+	CellWidth  int
+	CellHeight int
+	Gap        int
+	Caption    Caption
+}
+
+// ContactSheet runs each of the given files through the configured named
+// pipeline of its sidecar file and lays out the results in a grid.
+// Files without a sidecar are only loaded and oriented.
+func ContactSheet(ctx context.Context, c Conf, s ContactSheetConf, output string, files []string) error {
+	var err error
+	if c, err = c.Parse(); err != nil {
+		return err
+	}
+
+	switch s.Caption {
+	case CaptionNone, CaptionFilename, CaptionExif:
+	case "":
+		s.Caption = CaptionNone
+	default:
+		return fmt.Errorf("invalid caption '%s'", s.Caption)
+	}
+
+	cells := make([]pipeline.Element, len(files))
+	for i, file := range files {
+		var el pipeline.Element = element.CorrectOrientation()
+		root, err := LoadSidecar(c, file)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// No sidecar, only correct the orientation.
+		case err != nil:
+			return fmt.Errorf("%s: %w", file, err)
+		default:
+			pl, ok := root.Get(string(pipeline.NamedPrefix) + c.Pipeline)
+			if !ok {
+				return fmt.Errorf("%s: no pipeline found by name '%s'", file, c.Pipeline)
+			}
+			el = pl.Element
+		}
+
+		cells[i] = contactCell{
+			c:       c,
+			file:    file,
+			el:      el,
+			caption: s.Caption,
+			w:       s.CellWidth,
+			h:       s.CellHeight,
+		}
+	}
+
+	line := pipeline.New(
+		element.Grid(
+			s.Columns,
+			s.CellWidth,
+			s.CellHeight,
+			s.Gap,
+			element.RGB8(32, 32, 32),
+			cells...,
+		),
+		element.SaveFile(output, c.OutputExt, 92),
+	)
+
+	rctx := pipeline.NewContext(c.Verbose, os.Stderr, pipeline.ModeConvert, ctx)
+	_, err = line.Do(rctx, nil)
+	return err
+}
+
+// contactCell loads a file, runs it through el and fits the result in a
+// transparent w by h image with an optional caption underneath.
+// Each cell runs in its own context as sidecars copied between images would
+// otherwise share cache entries and variables.
+type contactCell struct {
+	c       Conf
+	file    string
+	el      pipeline.Element
+	caption Caption
+	w, h    int
+}
+
+func (c contactCell) Do(parent pipeline.Context, _ *img48.Img) (*img48.Img, error) {
+	ctx := pipeline.NewContext(c.c.Verbose, os.Stderr, pipeline.ModeConvert, parent)
+	img, err := element.LoadFile(c.file).Do(ctx, nil)
+	if err != nil {
+		return img, err
+	}
+
+	var text string
+	switch c.caption {
+	case CaptionFilename:
+		text = filepath.Base(c.file)
+	case CaptionExif:
+		text = exifCaption(img.Exif)
+	}
+
+	img, err = c.el.Do(ctx, img)
+	if err != nil || img == nil {
+		return img, err
+	}
+
+	var size float64
+	var ch int
+	if text != "" {
+		size = math.Max(10, float64(c.h)/18)
+		ch = int(size*1.6 + 0.5)
+	}
+
+	w, h := c.w, c.h-ch
+	if w <= 0 || h <= 0 {
+		return img, fmt.Errorf("contact sheet cells of %dx%d are too small", c.w, c.h)
+	}
+	img, err = element.Resize(w, h, element.KernelBox, core.ResizeMax|core.ResizeNoUpscale).Do(ctx, img)
+	if err != nil {
+		return img, err
+	}
+
+	dst := img48.NewAlpha(image.Rect(0, 0, c.w, c.h), nil)
+	pt := image.Point{(w - img.Rect.Dx()) / 2, (h - img.Rect.Dy()) / 2}
+	core.Draw(img, dst, pt, nil)
+
+	if text == "" {
+		return dst, nil
+	}
+
+	return element.TextBox(
+		0, h, c.w, ch,
+		element.TextAlignCenter,
+		element.TextVAlignMiddle,
+		size,
+		text,
+		element.RGB8(230, 230, 230),
+		element.FontGo,
+	).Do(ctx, dst)
+}
+
+// exifCaption summarizes the shooting parameters found in ex.
+func exifCaption(ex *exif.Exif) string {
+	const ifd = 0x8769
+
+	parts := make([]string, 0, 4)
+	if v, ok := exifRational(ex.Find(ifd, 0x920a)); ok {
+		parts = append(parts, fmt.Sprintf("%gmm", math.Round(v)))
+	}
+	if v, ok := exifRational(ex.Find(ifd, 0x829d)); ok {
+		parts = append(parts, fmt.Sprintf("f/%g", math.Round(v*10)/10))
+	}
+	if v, ok := exifRational(ex.Find(ifd, 0x829a)); ok && v > 0 {
+		if v < 1 {
+			parts = append(parts, fmt.Sprintf("1/%gs", math.Round(1/v)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%gs", math.Round(v*10)/10))
+		}
+	}
+	if v, ok := ex.Find(ifd, 0x8827).Value().Int(); ok {
+		parts = append(parts, fmt.Sprintf("ISO %d", v))
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+func exifRational(e *exif.Entry) (float64, bool) {
+	switch v := e.Value().Value.(type) {
+	case [][2]uint32:
+		if len(v) != 0 && v[0][1] != 0 {
+			return float64(v[0][0]) / float64(v[0][1]), true
+		}
+	case [][2]int32:
+		if len(v) != 0 && v[0][1] != 0 {
+			return float64(v[0][0]) / float64(v[0][1]), true
+		}
+	}
+
+	return 0, false
+}
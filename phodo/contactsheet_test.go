@@ -0,0 +1,97 @@
+package phodo
+
+import (
+	"testing"
+
+	"github.com/frizinak/phodo/exif"
+)
+
+func TestExifCaption(t *testing.T) {
+	const ifd = 0x8769
+	type tag struct {
+		tag, typ uint16
+		rat      [][2]int
+		ints     []int
+	}
+
+	tests := []struct {
+		tags []tag
+		exp  string
+	}{
+		{nil, ""},
+		{
+			[]tag{
+				{tag: 0x920a, typ: exif.TypeUrational, rat: [][2]int{{499, 10}}},
+				{tag: 0x829d, typ: exif.TypeUrational, rat: [][2]int{{28, 10}}},
+				{tag: 0x829a, typ: exif.TypeUrational, rat: [][2]int{{1, 250}}},
+				{tag: 0x8827, typ: exif.TypeUint16, ints: []int{400}},
+			},
+			"50mm  f/2.8  1/250s  ISO 400",
+		},
+		{
+			[]tag{
+				{tag: 0x829a, typ: exif.TypeUrational, rat: [][2]int{{25, 10}}},
+				{tag: 0x8827, typ: exif.TypeUint16, ints: []int{100}},
+			},
+			"2.5s  ISO 100",
+		},
+		{
+			[]tag{
+				{tag: 0x829d, typ: exif.TypeRational, rat: [][2]int{{56, 10}}},
+				{tag: 0x920a, typ: exif.TypeUrational, rat: [][2]int{{35, 0}}},
+				{tag: 0x829a, typ: exif.TypeUrational, rat: [][2]int{{0, 1}}},
+			},
+			"f/5.6",
+		},
+	}
+
+	for _, test := range tests {
+		ex := exif.New()
+		sub := ex.Ensure(0, ifd, exif.TypeUint32)
+		for _, tag := range test.tags {
+			e := sub.IFDSet.Ensure(0, tag.tag, tag.typ)
+			if tag.rat != nil {
+				e.SetRationals(tag.rat)
+				continue
+			}
+			e.SetInts(tag.ints)
+		}
+
+		if got := exifCaption(ex); got != test.exp {
+			t.Errorf("expected %q got %q", test.exp, got)
+		}
+	}
+
+	if got := exifCaption(nil); got != "" {
+		t.Errorf("expected an empty caption without exif, got %q", got)
+	}
+}
+
+func TestExifRational(t *testing.T) {
+	ex := exif.New()
+	ex.Ensure(0, 1, exif.TypeUrational).SetRationals([][2]int{{3, 2}})
+	ex.Ensure(0, 2, exif.TypeRational).SetRationals([][2]int{{-3, 4}})
+	ex.Ensure(0, 3, exif.TypeUrational).SetRationals([][2]int{{3, 0}})
+	ex.Ensure(0, 4, exif.TypeUint16).SetInts([]int{3})
+	ex.Ensure(0, 5, exif.TypeUrational)
+
+	tests := []struct {
+		tag uint16
+		exp float64
+		ok  bool
+	}{
+		{1, 1.5, true},
+		{2, -0.75, true},
+		{3, 0, false},
+		{4, 0, false},
+		{5, 0, false},
+		{6, 0, false},
+	}
+
+	for _, test := range tests {
+		v, ok := exifRational(ex.Find(test.tag))
+		if v != test.exp || ok != test.ok {
+			t.Errorf("tag %d: expected %g %t got %g %t", test.tag, test.exp, test.ok, v, ok)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package phodo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var imageExts = map[string]struct{}{
+	".jpg":  {},
+	".jpeg": {},
+	".png":  {},
+	".tif":  {},
+	".tiff": {},
+	".nef":  {},
+	".raf":  {},
+	".cr2":  {},
+	".arw":  {},
+	".dng":  {},
+	".orf":  {},
+	".rw2":  {},
+	".pef":  {},
+}
+
+// Images replaces each directory in paths with the images it contains,
+// sorted by name. Other paths are returned as is.
+func Images(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		stat, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.IsDir() {
+			files = append(files, p)
+			continue
+		}
+
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		dir := make([]string, 0, len(entries))
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if _, ok := imageExts[ext]; ok && !e.IsDir() {
+				dir = append(dir, filepath.Join(p, e.Name()))
+			}
+		}
+		sort.Strings(dir)
+		files = append(files, dir...)
+	}
+
+	return files, nil
+}
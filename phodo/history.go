@@ -0,0 +1,195 @@
+package phodo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// historyDir is the hidden directory next to a script in which its
+	// rendered versions are kept.
+	historyDir = ".phodo-history"
+	// historyMax is the number of versions kept per script.
+	historyMax = 200
+
+	historyTime = "20060102T150405.000000000Z"
+)
+
+// Version is a rendered version of a script.
+type Version struct {
+	Path string
+	Time time.Time
+}
+
+func (v Version) Read() ([]byte, error) { return os.ReadFile(v.Path) }
+
+func historyPath(script string) string {
+	return filepath.Join(filepath.Dir(script), historyDir, filepath.Base(script))
+}
+
+// History returns the rendered versions of script, oldest first.
+func History(script string) ([]Version, error) {
+	dir := historyPath(script)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Version, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".pho" {
+			continue
+		}
+		t, err := time.Parse(historyTime, strings.TrimSuffix(name, ".pho"))
+		if err != nil {
+			continue
+		}
+		l = append(l, Version{Path: filepath.Join(dir, name), Time: t})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Time.Before(l[j].Time) })
+
+	return l, nil
+}
+
+// recordHistory stores data as the latest version of script unless it is
+// identical to it.
+func recordHistory(script string, data []byte) error {
+	l, err := History(script)
+	if err != nil {
+		return err
+	}
+	if len(l) != 0 {
+		last, err := l[len(l)-1].Read()
+		if err == nil && bytes.Equal(last, data) {
+			return nil
+		}
+	}
+
+	dir := historyPath(script)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if len(l) != 0 && !now.After(l[len(l)-1].Time) {
+		now = l[len(l)-1].Time.Add(time.Nanosecond)
+	}
+	p := filepath.Join(dir, now.Format(historyTime)+".pho")
+	if err := replaceFile(p, data, 0o644); err != nil {
+		return err
+	}
+
+	for len(l) >= historyMax {
+		if err := os.Remove(l[0].Path); err != nil {
+			return err
+		}
+		l = l[1:]
+	}
+
+	return nil
+}
+
+// Restore replaces script with the given version.
+func Restore(script string, v Version) error {
+	data, err := v.Read()
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0o644)
+	if stat, err := os.Stat(script); err == nil {
+		perm = stat.Mode().Perm()
+	}
+
+	return replaceFile(script, data, perm)
+}
+
+// previousVersion returns the latest version before from (or before the
+// latest if from is empty) whose content differs from current.
+func previousVersion(script string, current []byte, from string) (Version, error) {
+	l, err := History(script)
+	if err != nil {
+		return Version{}, err
+	}
+
+	i := len(l) - 1
+	if from != "" {
+		for i >= 0 && l[i].Path != from {
+			i--
+		}
+		i--
+	}
+
+	for ; i >= 0; i-- {
+		data, err := l[i].Read()
+		if err != nil {
+			return Version{}, err
+		}
+		if !bytes.Equal(data, current) {
+			return l[i], nil
+		}
+	}
+
+	return Version{}, errors.New("no previous version")
+}
+
+// Diff writes a line based diff from a to b to w.
+func Diff(w io.Writer, a, b []byte) error {
+	al := strings.Split(strings.TrimSuffix(string(a), "\n"), "\n")
+	bl := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:]
+	// and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			switch {
+			case al[i] == bl[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var err error
+	line := func(prefix, l string) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s %s\n", prefix, l)
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			line(" ", al[i])
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			line("-", al[i])
+			i++
+		default:
+			line("+", bl[j])
+			j++
+		}
+	}
+
+	return err
+}
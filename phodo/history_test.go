@@ -0,0 +1,118 @@
+package phodo
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		exp  string
+	}{
+		{"a\nb\n", "a\nb\n", "  a\n  b\n"},
+		{"a\nb\n", "a\nc\n", "  a\n- b\n+ c\n"},
+		{"a\n", "a\nb\n", "  a\n+ b\n"},
+		{"a\nb\nc\n", "a\nc\n", "  a\n- b\n  c\n"},
+		{"a\nb", "b\na", "- a\n  b\n+ a\n"},
+		{
+			".main(\n    a\n    b\n)\n",
+			".main(\n    a\n    c\n    d\n)\n",
+			"  .main(\n      a\n-     b\n+     c\n+     d\n  )\n",
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		if err := Diff(buf, []byte(test.a), []byte(test.b)); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.exp {
+			t.Errorf("diff %q %q:\nexpected:\n%s\ngot:\n%s", test.a, test.b, test.exp, buf.String())
+		}
+	}
+}
+
+func TestPreviousVersion(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "a.jpg.pho")
+	for _, v := range []string{"1", "2", "2", "1", "2"} {
+		if err := recordHistory(script, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := History(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 4 {
+		t.Fatalf("expected identical versions in a row to be recorded once, got %d versions", len(l))
+	}
+
+	tests := []struct {
+		current string
+		from    int
+		exp     int
+	}{
+		{"2", -1, 2},
+		{"2", 2, 0},
+		{"2", 0, -1},
+		{"1", -1, 3},
+		{"1", 3, 1},
+		{"3", -1, 3},
+		{"3", 1, 0},
+	}
+
+	for _, test := range tests {
+		var from string
+		if test.from >= 0 {
+			from = l[test.from].Path
+		}
+		v, err := previousVersion(script, []byte(test.current), from)
+		if test.exp < 0 {
+			if err == nil {
+				t.Errorf("%s from %d: expected no previous version, got %s", test.current, test.from, v.Path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s from %d: %s", test.current, test.from, err)
+			continue
+		}
+		if v.Path != l[test.exp].Path {
+			t.Errorf("%s from %d: expected version %d, got %s", test.current, test.from, test.exp, v.Path)
+		}
+	}
+}
+
+func TestHistoryMax(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "a.jpg.pho")
+	for i := 0; i < historyMax+5; i++ {
+		if err := recordHistory(script, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := History(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != historyMax {
+		t.Fatalf("expected %d versions, got %d", historyMax, len(l))
+	}
+	read := func(v Version) string {
+		data, err := v.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if v := read(l[0]); v != "5" {
+		t.Errorf("expected the oldest versions to be removed, first is %s", v)
+	}
+	if v := read(l[len(l)-1]); v != fmt.Sprint(historyMax+4) {
+		t.Errorf("expected the latest version to be kept, last is %s", v)
+	}
+}
@@ -0,0 +1,80 @@
+package phodo
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/frizinak/phodo/edit"
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+const (
+	// Pixels with an average value at or below clipShadows or at or above
+	// clipHighlights are highlighted by the clipping overlay.
+	clipShadows    = 0.002
+	clipHighlights = 0.998
+)
+
+// overlays keeps the editor histogram and clipping overlays in sync with
+// the latest result. They are calculated on the side and never alter what
+// the pipeline produced.
+type overlays struct {
+	sem  sync.Mutex
+	v    *edit.Viewer
+	ctx  pipeline.Context
+	out  io.Writer
+	img  *img48.Img
+	hist bool
+	clip bool
+}
+
+func (o *overlays) set(img *img48.Img) {
+	o.sem.Lock()
+	o.img = img
+	o.update()
+	o.sem.Unlock()
+}
+
+func (o *overlays) toggleHistogram() {
+	o.sem.Lock()
+	o.hist = !o.hist
+	o.update()
+	o.sem.Unlock()
+}
+
+func (o *overlays) toggleClipping() {
+	o.sem.Lock()
+	o.clip = !o.clip
+	o.update()
+	o.sem.Unlock()
+}
+
+func (o *overlays) update() {
+	var hist, clip *img48.Img
+	if o.img != nil && o.hist {
+		var err error
+		hist, err = element.Histogram().
+			RGBImage().
+			Size(256, 100).
+			BarSize(1).
+			Interpolated().
+			Do(o.ctx, o.img)
+		if err != nil {
+			fmt.Fprintln(o.out, err)
+			hist = nil
+		}
+	}
+
+	if o.img != nil && o.clip {
+		clip = core.ImageCopy(o.img)
+		core.DrawClipping(core.SimpleColor{B: 1<<16 - 1}, clip, clipShadows, false)
+		core.DrawClipping(core.SimpleColor{R: 1<<16 - 1}, clip, clipHighlights, false)
+	}
+
+	o.v.SetHistogram(hist)
+	o.v.SetClipping(clip)
+}
@@ -2,14 +2,18 @@ package phodo
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"image"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,10 +23,15 @@ import (
 	"github.com/frizinak/phodo/pipeline/element"
 	"github.com/frizinak/phodo/pipeline/element/core"
 	"github.com/google/shlex"
+	xdraw "golang.org/x/image/draw"
 )
 
 type PixelReporter func(x, y int, r, g, b uint16)
 
+// previewMaxScale is the largest scale of the image to the window at which
+// the editor renders a preview before the full resolution image.
+const previewMaxScale = 0.5
+
 type Conf struct {
 	Editor       []string
 	EditorString string
@@ -208,59 +217,275 @@ func (c Conf) parseVars() (map[string]string, error) {
 	return m, nil
 }
 
-func Editor(ctx context.Context, c Conf, file string) error {
-	c.inputFile = file
+// Editor shows the result of the sidecar of the given image and renders it
+// again every time the sidecar changes. With multiple images the viewer can
+// switch between them, each with their own sidecar.
+func Editor(ctx context.Context, c Conf, files ...string) error {
+	if len(files) == 0 {
+		return errors.New("no images to edit")
+	}
+
 	var err error
 	c, err = c.Parse()
 	if err != nil {
-		return nil
+		return err
+	}
+	base := c
+	forFile := func(file string) (Conf, error) {
+		c := base
+		c.inputFile = file
+		return c.Parse()
 	}
 
-	{
-		s, err := os.Stat(c.Script)
-		if os.IsNotExist(err) {
-			if s != nil && s.IsDir() {
-				return fmt.Errorf("'%s' is a directory", c.Script)
+	current := 0
+	c, err = forFile(files[current])
+	if err != nil {
+		return err
+	}
+	if err := ensureScript(c); err != nil {
+		return err
+	}
+
+	// title returns the window title for the image in c.
+	title := func(c Conf, status string) string {
+		t := "phodo edit: " + filepath.Base(c.inputFile)
+		if len(files) > 1 {
+			for i, f := range files {
+				if f == c.inputFile {
+					t = fmt.Sprintf("%s [%d/%d]", t, i+1, len(files))
+					break
+				}
 			}
+		}
+		if status != "" {
+			t = fmt.Sprintf("%s (%s)", t, status)
+		}
+		return t
+	}
+
+	// img is the latest result, it is set on the render goroutine and read
+	// on the thread of the viewer.
+	var imgSem sync.Mutex
+	var img *img48.Img
+	latest := func() *img48.Img {
+		imgSem.Lock()
+		defer imgSem.Unlock()
+		return img
+	}
+
+	var exit func()
+	ctx, exit = context.WithCancel(ctx)
+	ren := newRenderer(ctx, c)
+
+	v := &edit.Viewer{}
+	v.SetTitle(title(c, ""))
+	var conf edit.Config
+	ov := &overlays{
+		v:   v,
+		ctx: pipeline.NewContext(0, io.Discard, pipeline.ModeEdit, ctx),
+		out: c.out,
+	}
+
+	var tool edit.Tool
+	var radius int
+	setTool := func(t edit.Tool) {
+		if tool == t {
+			t = edit.ToolNone
+		}
+		tool = t
+		if img := latest(); radius == 0 && img != nil {
+			b := img.Bounds()
+			radius = int(math.Max(4, math.Min(float64(b.Dx()), float64(b.Dy()))/50))
+		}
+		if t != edit.ToolNone {
+			v.SetCompare(edit.CompareNone)
+		}
+		v.SetTool(t, radius)
+	}
+
+	confirm := func() {
+		sel, ok := v.Selection()
+		if !ok {
+			return
+		}
+		defer v.SetTool(tool, radius)
 
-			if c.DefaultPipelines == nil {
-				return fmt.Errorf("%w and no default pipeline was provided", err)
+		var fn toolFunc
+		switch sel.Tool {
+		case edit.ToolRect:
+			if sel.From == sel.To {
+				return
+			}
+			r := image.Rectangle{sel.From, sel.To}.Canon()
+			r.Max = r.Max.Add(image.Point{1, 1})
+			fn = func(last pipeline.Element) (pipeline.Element, bool) {
+				return element.ToolCrop(last, r)
+			}
+		case edit.ToolSpot:
+			fn = func(last pipeline.Element) (pipeline.Element, bool) {
+				return element.ToolWhiteBalanceSpot(last, sel.To.X, sel.To.Y, sel.Radius)
+			}
+		case edit.ToolClone:
+			if sel.From == sel.To {
+				return
+			}
+			fn = func(last pipeline.Element) (pipeline.Element, bool) {
+				// heal-spot sizes are diameters.
+				return element.HealSpot(sel.From.X, sel.From.Y, sel.To.X, sel.To.Y, 2*sel.Radius, sel.Radius), false
+			}
+		case edit.ToolLine:
+			if sel.From == sel.To {
+				return
 			}
+			fn = func(last pipeline.Element) (pipeline.Element, bool) {
+				return element.ToolStraighten(last, sel.From.X, sel.From.Y, sel.To.X, sel.To.Y)
+			}
+		default:
+			return
+		}
+
+		if err := writeTool(c, fn); err != nil {
+			fmt.Fprintln(c.out, err)
+		}
+	}
+
+	var undoFrom string
+	var undoData []byte
+
+	// open switches to the image at index i, copyScript copies the sidecar of
+	// the current image to it first. The sidecar it replaces is added to its
+	// history so it can be restored.
+	open := func(i int, copyScript bool) {
+		if i < 0 || i >= len(files) || i == current {
+			return
+		}
 
+		nc, err := forFile(files[i])
+		if err == nil && copyScript && nc.Script != c.Script {
 			err = func() error {
-				f, err := os.Create(c.Script)
-				if err != nil {
+				old, err := os.ReadFile(nc.Script)
+				if err == nil {
+					err = recordHistory(nc.Script, old)
+				}
+				if err != nil && !os.IsNotExist(err) {
 					return err
 				}
-				_, err = fmt.Fprint(f, c.DefaultPipelines())
-				f.Close()
-				return err
+				return copyFile(c.Script, nc.Script)
 			}()
 		}
+		if err == nil {
+			err = ensureScript(nc)
+		}
 		if err != nil {
-			return err
+			fmt.Fprintln(c.out, err)
+			return
 		}
+
+		c, current = nc, i
+		undoFrom = ""
+		v.SetTool(tool, radius)
+		v.SetBanner(nil)
+		v.SetTitle(title(c, ""))
+		ren.open(c)
+		fmt.Fprintf(c.out, "%d/%d %s\n", current+1, len(files), c.inputFile)
 	}
 
-	var fullRefresh bool
-	var fullRefreshing bool
-	var img *img48.Img
+	// stepBack restores the rendered version of the script before the
+	// current one, repeatedly stepping back further while it is unchanged.
+	stepBack := func() {
+		data, err := os.ReadFile(c.Script)
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			return
+		}
+		if !bytes.Equal(data, undoData) {
+			undoFrom = ""
+		}
 
-	var exit func()
-	ctx, exit = context.WithCancel(ctx)
+		ver, err := previousVersion(c.Script, data, undoFrom)
+		if err == nil {
+			undoData, err = ver.Read()
+		}
+		if err == nil {
+			err = Restore(c.Script, ver)
+		}
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			return
+		}
+		undoFrom = ver.Path
+		fmt.Fprintf(c.out, "restored version of %s\n", ver.Time.Local().Format(time.DateTime))
+	}
 
-	v := &edit.Viewer{}
-	var conf edit.Config
 	conf.OnKey = func(r rune) {
 		switch r {
 		case 'q':
 			exit()
 		case 'r':
-			fullRefresh = true
+			ren.refreshNoCache()
+		case 'n':
+			open(current+1, false)
+		case 'p':
+			open(current-1, false)
+		case 'y':
+			open(current+1, true)
+		case 'u':
+			stepBack()
+		case 'g':
+			go ov.toggleHistogram()
+		case 'j':
+			go ov.toggleClipping()
+		case 'c':
+			setTool(edit.ToolRect)
+		case 'w':
+			setTool(edit.ToolSpot)
+		case 'h':
+			setTool(edit.ToolClone)
+		case 't':
+			setTool(edit.ToolLine)
+		case '[', ']':
+			if r == '[' {
+				radius = int(float64(radius) / 1.25)
+			} else {
+				radius = int(math.Ceil(float64(radius) * 1.25))
+			}
+			if radius < 1 {
+				radius = 1
+			}
+			v.SetRadius(radius)
+		case edit.KeyEnter:
+			confirm()
+		case edit.KeyEscape:
+			if _, ok := v.Selection(); ok {
+				v.SetTool(tool, radius)
+				break
+			}
+			setTool(edit.ToolNone)
+		case 'b':
+			mode := edit.CompareBefore
+			if v.Compare() == mode {
+				mode = edit.CompareNone
+			}
+			v.SetCompare(mode)
+		case 's':
+			mode := edit.CompareSplit
+			if v.Compare() == mode {
+				mode = edit.CompareNone
+			}
+			v.SetCompare(mode)
 		}
 	}
 
+	var winSem sync.Mutex
+	win := image.Point{800, 800}
+	conf.OnResize = func(w, h int) {
+		winSem.Lock()
+		win = image.Point{w, h}
+		winSem.Unlock()
+	}
+
 	conf.OnClick = func(x, y int) {
+		img := latest()
 		if img == nil {
 			return
 		}
@@ -268,17 +493,6 @@ func Editor(ctx context.Context, c Conf, file string) error {
 		c.pix(x, y, uint16(r), uint16(g), uint16(b))
 	}
 
-	var gerr error
-	done := make(chan struct{}, 1)
-	quit := make(chan struct{})
-	spawned := make(chan struct{}, 1)
-	go func() {
-		if err := v.Run(conf, quit, spawned); err != nil {
-			gerr = err
-		}
-		done <- struct{}{}
-	}()
-
 	var cmd *exec.Cmd
 	{
 		editArgs := make([]string, len(c.Editor))
@@ -301,6 +515,17 @@ func Editor(ctx context.Context, c Conf, file string) error {
 		}
 	}
 
+	var gerr error
+	done := make(chan struct{}, 1)
+	quit := make(chan struct{})
+	spawned := make(chan struct{}, 1)
+	go func() {
+		if err := v.Run(conf, quit, spawned); err != nil {
+			gerr = err
+		}
+		done <- struct{}{}
+	}()
+
 	<-spawned
 	if cmd != nil {
 		if err := cmd.Start(); err != nil {
@@ -315,147 +540,96 @@ func Editor(ctx context.Context, c Conf, file string) error {
 		}()
 	}
 
-	load := pipeline.New(
-		element.Once(element.LoadFile(c.inputFile)),
-	)
-
-	tShort := time.Millisecond * 20
-	tError := time.Millisecond * 1000
-
-	var cancel func()
-	rctx := pipeline.NewContext(c.Verbose, os.Stderr, pipeline.ModeEdit, context.Background())
-	newCtx := func() {
-		ictx, cncl := context.WithCancel(ctx)
-		rctx.Context, cancel = ictx, cncl
-	}
-	newCtx()
-
-	print := func(left, right string) {
-		rctx.PrintAlert("%-39s %38s", left, right)
+	// The preview is rendered from a copy of the image that fits the window,
+	// it has its own context so cached results of both don't mix.
+	var full image.Point
+	var preview struct {
+		scale  float64
+		parent *pipeline.SimpleContext
+		src    pipeline.Element
+		ctx    *pipeline.SimpleContext
+		load   pipeline.Element
+	}
+	previewScale := func() float64 {
+		winSem.Lock()
+		w := win
+		winSem.Unlock()
+		if full.X == 0 || full.Y == 0 {
+			return 1
+		}
+		return math.Min(float64(w.X)/float64(full.X), float64(w.Y)/float64(full.Y))
 	}
+	ren.preview = func(load, el pipeline.Element) {
+		s := previewScale()
+		if s > previewMaxScale {
+			return
+		}
 
-	tick := make(chan struct{})
-	go func() {
-		var res *pipeline.Root
-		for range tick {
-			if rctx.Err() == context.Canceled {
-				newCtx()
-			}
-
-			s := time.Now()
-			f, err := os.Open(c.Script)
-			if err != nil {
-				fmt.Fprintln(c.out, err)
-				time.Sleep(tError)
-				continue
-			}
-
-			if fullRefresh {
-				fullRefreshing = true
-				rctx = pipeline.NewContext(c.Verbose, os.Stderr, pipeline.ModeEdit, context.Background())
-				newCtx()
-				res = nil
-			}
-
-			res, err = pipeline.NewDecoder(f, c.vars, c.aliases).Decode(res)
-			f.Close()
-			if err != nil {
-				fmt.Fprintln(c.out, err)
-				time.Sleep(tError)
-				continue
-			}
-
-			e, ok := res.Get(string(pipeline.NamedPrefix) + c.Pipeline)
-			if !ok {
-				fmt.Fprintf(c.out, "no pipeline named '%s'\n", c.Pipeline)
-				time.Sleep(tError)
-				continue
-			}
-
-			if e.Cached && !fullRefresh {
-				time.Sleep(tShort)
-				continue
-			}
-
-			out, err := pipeline.New(
+		if preview.ctx == nil || preview.scale != s || preview.parent != ren.ctx || preview.src != load {
+			preview.scale = s
+			preview.parent = ren.ctx
+			preview.src = load
+			pc, _, _ := ren.current()
+			preview.ctx = pipeline.NewContext(pc.Verbose, os.Stderr, pipeline.ModeEdit, ren.ctx.Context)
+			pipeline.SetScale(preview.ctx, s)
+			w, h := int(math.Round(float64(full.X)*s)), int(math.Round(float64(full.Y)*s))
+			preview.load = element.Once(
 				load,
-				e.Element,
-			).Do(rctx, nil)
-
-			l := "Render"
-			if fullRefreshing {
-				l = "Render (no cache)"
-				fullRefreshing = false
-				fullRefresh = false
-			}
-
-			if err == context.Canceled {
-				continue
-			}
-
-			if err != nil {
-				fmt.Fprintln(c.out, err)
-				time.Sleep(tError)
-				continue
-			}
-
-			img = core.ImageDiscard(out)
-			v.Set(img)
+				pipeline.ElementFunc(func(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+					return core.ImageResize(img, *xdraw.BiLinear, 0, w, h), nil
+				}),
+			)
+		}
+		preview.ctx.Context = ren.ctx.Context
 
-			if c.Verbose >= pipeline.VerboseTime {
-				print(l, time.Since(s).Round(time.Millisecond).String())
-			}
+		t := time.Now()
+		out, err := pipeline.New(preview.load, el).Do(preview.ctx, nil)
+		if err != nil {
+			// Reported by the full resolution render.
+			return
 		}
-	}()
 
-	s := time.Now()
-	_, err = load.Do(rctx, nil)
-	if err != nil {
-		return err
-	}
-	if c.Verbose >= pipeline.VerboseTime {
-		print("Loading image", time.Since(s).Round(time.Millisecond).String())
+		out = core.ImageDiscard(out)
+		v.SetPreview(out, s)
+		ov.set(out)
+		pc, _, _ := ren.current()
+		ren.took(pc, "Preview", t)
 	}
 
-	var lastMod time.Time
-	for {
-		if err := ctx.Err(); err != nil {
-			close(tick)
-			if err == context.Canceled {
-				break
-			}
+	ren.loaded = func(orig *img48.Img) {
+		v.SetOriginal(orig)
+		full = image.Point{orig.Rect.Dx(), orig.Rect.Dy()}
+	}
 
-			gerr = err
-			break
-		}
+	ren.rendered = func(out *img48.Img) {
+		imgSem.Lock()
+		img = out
+		imgSem.Unlock()
+		v.Set(out)
+		v.SetBanner(nil)
+		ov.set(out)
+	}
 
-		s, err := os.Stat(c.Script)
-		if err != nil {
-			fmt.Fprintln(c.out, err)
-			time.Sleep(tError)
-			continue
+	ren.failed = func(err error) {
+		winSem.Lock()
+		w := win.X
+		winSem.Unlock()
+		banner, berr := errorBanner(err, w-16)
+		if berr != nil {
+			c, _, _ := ren.current()
+			fmt.Fprintln(c.out, berr)
+			return
 		}
+		v.SetBanner(banner)
+	}
 
-		mod := s.ModTime()
-		if !fullRefresh && !mod.After(lastMod) {
-			time.Sleep(tShort)
-			continue
-		}
-		lastMod = mod
+	ren.timed = func(label string, d time.Duration) {
+		c, _, _ := ren.current()
+		v.SetTitle(title(c, fmt.Sprintf("%s %s", label, d)))
+	}
 
-		ok := false
-		select {
-		case tick <- struct{}{}:
-			ok = true
-		default:
-			cancel()
-		}
-		if !ok {
-			tick <- struct{}{}
-		}
-		for fullRefresh || fullRefreshing {
-			time.Sleep(tShort)
-		}
+	if err := ren.run(ctx); err != nil {
+		gerr = err
 	}
 
 	// ignore errors after this point
@@ -0,0 +1,330 @@
+package phodo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+// renderer watches the script and renders the configured pipeline on the
+// input image every time it changes. It drives both the editor and the
+// preview server.
+type renderer struct {
+	// sem guards c, load and gen which change when another image is opened
+	// and cancel which is replaced along with the context of ctx.
+	sem    sync.Mutex
+	c      Conf
+	load   *pipeline.Pipeline
+	gen    int
+	cancel func()
+
+	// ctx is replaced on a render without cache, only use it from the
+	// render callbacks.
+	ctx    *pipeline.SimpleContext
+	parent context.Context
+
+	// These are set from other goroutines, e.g.: by the viewer or http
+	// handlers.
+	force          atomic.Bool
+	forced         atomic.Bool
+	fullRefresh    atomic.Bool
+	fullRefreshing atomic.Bool
+
+	// loaded is called with the original image once it is loaded.
+	loaded func(orig *img48.Img)
+	// preview is called before each render with the loader of the image and
+	// the pipeline that is about to be rendered.
+	preview func(load, el pipeline.Element)
+	// rendered is called with each result.
+	rendered func(img *img48.Img)
+	// failed is called with each error that stopped a render.
+	failed func(err error)
+	// timed is called with the duration of each load and render.
+	timed func(label string, d time.Duration)
+}
+
+func newRenderer(ctx context.Context, c Conf) *renderer {
+	r := &renderer{
+		c:      c,
+		parent: ctx,
+		load:   newLoader(c),
+		ctx:    pipeline.NewContext(c.Verbose, os.Stderr, pipeline.ModeEdit, context.Background()),
+	}
+	r.newCtx()
+	return r
+}
+
+func newLoader(c Conf) *pipeline.Pipeline {
+	return pipeline.New(element.Once(element.LoadFile(c.inputFile)))
+}
+
+// open switches to the image and script in c. The pipeline context and with
+// it any loaded fonts are kept, the cache is cleared once the new image is
+// loaded as cached results depend on their input.
+func (r *renderer) open(c Conf) {
+	r.sem.Lock()
+	r.c, r.load = c, newLoader(c)
+	r.gen++
+	r.cancel()
+	r.sem.Unlock()
+	r.force.Store(true)
+}
+
+func (r *renderer) current() (Conf, *pipeline.Pipeline, int) {
+	r.sem.Lock()
+	defer r.sem.Unlock()
+	return r.c, r.load, r.gen
+}
+
+// ensureScript creates the script using c.DefaultPipelines if it does not
+// exist.
+func ensureScript(c Conf) error {
+	s, err := os.Stat(c.Script)
+	if os.IsNotExist(err) {
+		if s != nil && s.IsDir() {
+			return fmt.Errorf("'%s' is a directory", c.Script)
+		}
+
+		if c.DefaultPipelines == nil {
+			return fmt.Errorf("%w and no default pipeline was provided", err)
+		}
+
+		err = func() error {
+			f, err := os.Create(c.Script)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(f, c.DefaultPipelines())
+			f.Close()
+			return err
+		}()
+	}
+
+	return err
+}
+
+func (r *renderer) newCtx() {
+	ictx, cncl := context.WithCancel(r.parent)
+	r.ctx.Context = ictx
+	r.sem.Lock()
+	r.cancel = cncl
+	r.sem.Unlock()
+}
+
+// stop cancels the current render.
+func (r *renderer) stop() {
+	r.sem.Lock()
+	r.cancel()
+	r.sem.Unlock()
+}
+
+func (r *renderer) print(left, right string) {
+	r.ctx.PrintAlert("%-39s %38s", left, right)
+}
+
+func (r *renderer) fail(c Conf, err error) {
+	fmt.Fprintln(c.out, err)
+	if r.failed != nil {
+		r.failed(err)
+	}
+}
+
+func (r *renderer) took(c Conf, label string, since time.Time) {
+	d := time.Since(since).Round(time.Millisecond)
+	if c.Verbose >= pipeline.VerboseTime {
+		r.print(label, d.String())
+	}
+	if r.timed != nil {
+		r.timed(label, d)
+	}
+}
+
+// refresh renders the pipeline again even if the script did not change.
+func (r *renderer) refresh() { r.force.Store(true) }
+
+// refreshNoCache renders the pipeline again from scratch.
+func (r *renderer) refreshNoCache() { r.fullRefresh.Store(true) }
+
+func (r *renderer) render(tick <-chan struct{}, loaded int) {
+	tShort := time.Millisecond * 20
+	tError := time.Millisecond * 1000
+
+	var res *pipeline.Root
+	var file string
+	for range tick {
+		if r.ctx.Err() == context.Canceled {
+			r.newCtx()
+		}
+
+		c, load, gen := r.current()
+		if gen != loaded {
+			s := time.Now()
+			orig, err := load.Do(r.ctx, nil)
+			if err == context.Canceled {
+				continue
+			}
+			if err != nil {
+				r.fail(c, err)
+				time.Sleep(tError)
+				continue
+			}
+			loaded, res = gen, nil
+			if c.inputFile != file {
+				if file != "" {
+					r.ctx.Get(element.CacheStorageName).(*element.CacheContainer).Clear()
+				}
+				file = c.inputFile
+			}
+			if r.loaded != nil {
+				r.loaded(orig)
+			}
+			r.took(c, "Loading image", s)
+		}
+
+		s := time.Now()
+		data, err := os.ReadFile(c.Script)
+		if err != nil {
+			r.fail(c, err)
+			time.Sleep(tError)
+			continue
+		}
+
+		if r.fullRefresh.Load() {
+			r.fullRefreshing.Store(true)
+			r.ctx = pipeline.NewContext(c.Verbose, os.Stderr, pipeline.ModeEdit, context.Background())
+			r.newCtx()
+			res = nil
+		}
+
+		res, err = pipeline.NewDecoder(bytes.NewReader(data), c.vars, c.aliases).Decode(res)
+		if err != nil {
+			r.fail(c, err)
+			time.Sleep(tError)
+			continue
+		}
+
+		e, ok := res.Get(string(pipeline.NamedPrefix) + c.Pipeline)
+		if !ok {
+			r.fail(c, fmt.Errorf("no pipeline named '%s'", c.Pipeline))
+			time.Sleep(tError)
+			continue
+		}
+
+		forced := r.forced.Swap(false)
+		if e.Cached && !forced && !r.fullRefresh.Load() {
+			time.Sleep(tShort)
+			continue
+		}
+
+		if r.preview != nil {
+			r.preview(load, e.Element)
+		}
+
+		out, err := pipeline.New(
+			load,
+			e.Element,
+		).Do(r.ctx, nil)
+
+		l := "Render"
+		if r.fullRefreshing.Load() {
+			l = "Render (no cache)"
+			r.fullRefresh.Store(false)
+			r.fullRefreshing.Store(false)
+		}
+
+		if err == context.Canceled {
+			continue
+		}
+
+		if err != nil {
+			r.fail(c, err)
+			time.Sleep(tError)
+			continue
+		}
+
+		if _, _, now := r.current(); now != gen {
+			// Another image was opened in the meantime.
+			continue
+		}
+		r.rendered(core.ImageDiscard(out))
+		r.took(c, l, s)
+
+		if err := recordHistory(c.Script, data); err != nil {
+			fmt.Fprintln(c.out, err)
+		}
+	}
+}
+
+// run loads the image and renders the pipeline each time the script
+// changes until ctx is done.
+func (r *renderer) run(ctx context.Context) error {
+	tShort := time.Millisecond * 20
+	tError := time.Millisecond * 1000
+
+	c, load, gen := r.current()
+	s := time.Now()
+	orig, err := load.Do(r.ctx, nil)
+	if err != nil {
+		return err
+	}
+	if r.loaded != nil {
+		r.loaded(orig)
+	}
+	r.took(c, "Loading image", s)
+
+	tick := make(chan struct{})
+	go r.render(tick, gen)
+	defer close(tick)
+
+	var lastMod time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			if err == context.Canceled {
+				return nil
+			}
+			return err
+		}
+
+		c, _, _ := r.current()
+		s, err := os.Stat(c.Script)
+		if err != nil {
+			r.fail(c, err)
+			time.Sleep(tError)
+			continue
+		}
+
+		mod := s.ModTime()
+		force := r.force.Swap(false)
+		if !force && !r.fullRefresh.Load() && !mod.After(lastMod) {
+			time.Sleep(tShort)
+			continue
+		}
+		lastMod = mod
+		if force {
+			r.forced.Store(true)
+		}
+
+		ok := false
+		select {
+		case tick <- struct{}{}:
+			ok = true
+		default:
+			r.stop()
+		}
+		if !ok {
+			tick <- struct{}{}
+		}
+		for r.fullRefresh.Load() || r.fullRefreshing.Load() {
+			time.Sleep(tShort)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package phodo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+const serveQuality = 92
+
+// Serve runs the same render loop as Editor but serves the results over
+// HTTP on addr instead of showing them in a window.
+//
+//	GET  /                  page that shows the result
+//	GET  /image             latest result as a jpeg
+//	GET  /events            server-sent 'render' events with the result version
+//	GET  /pixel?x=X&y=Y     16-bit color of the result at x,y as json
+//	POST /refresh           render again
+//	POST /refresh-no-cache  render again without cache
+func Serve(ctx context.Context, c Conf, file, addr string) error {
+	c.inputFile = file
+	var err error
+	c, err = c.Parse()
+	if err != nil {
+		return err
+	}
+
+	if err := ensureScript(c); err != nil {
+		return err
+	}
+
+	ctx, exit := context.WithCancel(ctx)
+	defer exit()
+
+	ren := newRenderer(ctx, c)
+	s := &server{subs: make(map[chan int]struct{})}
+	ren.rendered = s.set
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:     s.handler(ren),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	var serr error
+	done := make(chan struct{})
+	go func() {
+		if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			serr = err
+		}
+		exit()
+		close(done)
+	}()
+
+	fmt.Fprintf(c.out, "serving on http://%s\n", l.Addr())
+	err = ren.run(ctx)
+
+	sctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_ = srv.Shutdown(sctx)
+	<-done
+
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+type server struct {
+	sem     sync.Mutex
+	img     *img48.Img
+	version int
+	jpeg    []byte
+	jpegV   int
+	subs    map[chan int]struct{}
+}
+
+func (s *server) set(img *img48.Img) {
+	s.sem.Lock()
+	s.img = img
+	s.version++
+	for sub := range s.subs {
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- s.version
+	}
+	s.sem.Unlock()
+}
+
+func (s *server) latest() *img48.Img {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	return s.img
+}
+
+// encoded returns the latest result as a jpeg. The image is encoded outside
+// of the lock so new results and subscribers aren't held up by it.
+func (s *server) encoded() ([]byte, error) {
+	s.sem.Lock()
+	img, version := s.img, s.version
+	jpeg, jpegV := s.jpeg, s.jpegV
+	s.sem.Unlock()
+
+	if img == nil {
+		return nil, nil
+	}
+	if jpegV == version {
+		return jpeg, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := core.ImageEncode(buf, img, ".jpg", serveQuality); err != nil {
+		return nil, err
+	}
+
+	s.sem.Lock()
+	if version > s.jpegV {
+		s.jpeg, s.jpegV = buf.Bytes(), version
+	}
+	s.sem.Unlock()
+	return buf.Bytes(), nil
+}
+
+func (s *server) subscribe() chan int {
+	sub := make(chan int, 1)
+	s.sem.Lock()
+	s.subs[sub] = struct{}{}
+	if s.img != nil {
+		sub <- s.version
+	}
+	s.sem.Unlock()
+	return sub
+}
+
+func (s *server) unsubscribe(sub chan int) {
+	s.sem.Lock()
+	delete(s.subs, sub)
+	s.sem.Unlock()
+}
+
+func (s *server) handler(ren *renderer) http.Handler {
+	mux := http.NewServeMux()
+	get := func(path string, h http.HandlerFunc) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h(w, r)
+		})
+	}
+	post := func(path string, f func()) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			f()
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	get("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, servePage)
+	})
+
+	get("/image", func(w http.ResponseWriter, r *http.Request) {
+		data, err := s.encoded()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data == nil {
+			http.Error(w, "nothing rendered yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	})
+
+	get("/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		sub := s.subscribe()
+		defer s.unsubscribe(sub)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case v := <-sub:
+				fmt.Fprintf(w, "event: render\ndata: %d\n\n", v)
+				flusher.Flush()
+			}
+		}
+	})
+
+	get("/pixel", func(w http.ResponseWriter, r *http.Request) {
+		x, errx := strconv.Atoi(r.URL.Query().Get("x"))
+		y, erry := strconv.Atoi(r.URL.Query().Get("y"))
+		if errx != nil || erry != nil {
+			http.Error(w, "x and y should be integers", http.StatusBadRequest)
+			return
+		}
+
+		img := s.latest()
+		if img == nil || !image.Pt(x, y).In(img.Rect) {
+			http.Error(w, "no such pixel", http.StatusNotFound)
+			return
+		}
+
+		cr, cg, cb, _ := img.At(x, y).RGBA()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			X int    `json:"x"`
+			Y int    `json:"y"`
+			R uint16 `json:"r"`
+			G uint16 `json:"g"`
+			B uint16 `json:"b"`
+		}{x, y, uint16(cr), uint16(cg), uint16(cb)})
+	})
+
+	post("/refresh", ren.refresh)
+	post("/refresh-no-cache", ren.refreshNoCache)
+
+	return mux
+}
+
+const servePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>phodo</title>
+<style>
+body { margin: 0; background: #222; color: #ddd; font: 13px monospace; }
+#info { height: 2em; line-height: 2em; text-align: center; }
+img { display: block; margin: auto; max-width: 100vw; max-height: calc(100vh - 2em); cursor: crosshair; }
+</style>
+</head>
+<body>
+<div id="info">r: render without cache, click: pixel value</div>
+<img id="img" alt="">
+<script>
+const img = document.getElementById('img');
+const info = document.getElementById('info');
+new EventSource('events').addEventListener('render', e => {
+    img.src = 'image?v=' + e.data;
+});
+img.addEventListener('click', e => {
+    const x = Math.floor(e.offsetX * img.naturalWidth / img.clientWidth);
+    const y = Math.floor(e.offsetY * img.naturalHeight / img.clientHeight);
+    fetch('pixel?x=' + x + '&y=' + y).then(r => r.json()).then(p => {
+        info.textContent = p.x + ',' + p.y + ': ' + p.r + ' ' + p.g + ' ' + p.b;
+    });
+});
+document.addEventListener('keydown', e => {
+    if (e.key === 'r') {
+        fetch('refresh-no-cache', {method: 'POST'});
+    }
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,210 @@
+package phodo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+func newTestServer() *server {
+	return &server{subs: make(map[chan int]struct{})}
+}
+
+func serveRequest(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestServeMethods(t *testing.T) {
+	s := newTestServer()
+	ren := &renderer{}
+	h := s.handler(ren)
+
+	tests := []struct {
+		method, target string
+		exp            int
+	}{
+		{http.MethodGet, "/", http.StatusOK},
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nope", http.StatusNotFound},
+		{http.MethodGet, "/image", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/image", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/events", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/pixel?x=0&y=0", http.StatusNotFound},
+		{http.MethodPost, "/pixel?x=0&y=0", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/refresh", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/refresh-no-cache", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(h, test.method, test.target)
+		if w.Code != test.exp {
+			t.Errorf("%s %s: expected %d got %d", test.method, test.target, test.exp, w.Code)
+		}
+	}
+	if ren.force.Load() || ren.fullRefresh.Load() {
+		t.Fatal("refresh triggered by a GET request")
+	}
+
+	if w := serveRequest(h, http.MethodPost, "/refresh"); w.Code != http.StatusNoContent {
+		t.Errorf("POST /refresh: expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	if !ren.force.Load() || ren.fullRefresh.Load() {
+		t.Error("POST /refresh should only force a render")
+	}
+
+	if w := serveRequest(h, http.MethodPost, "/refresh-no-cache"); w.Code != http.StatusNoContent {
+		t.Errorf("POST /refresh-no-cache: expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	if !ren.fullRefresh.Load() {
+		t.Error("POST /refresh-no-cache should force a full render")
+	}
+}
+
+func TestServePixel(t *testing.T) {
+	s := newTestServer()
+	h := s.handler(&renderer{})
+
+	img := img48.New(image.Rect(0, 0, 4, 3), nil)
+	img.Set(3, 2, color.RGBA64{R: 1, G: 2, B: 65535, A: 65535})
+	s.set(img)
+
+	tests := []struct {
+		query string
+		exp   int
+	}{
+		{"", http.StatusBadRequest},
+		{"x=1", http.StatusBadRequest},
+		{"x=a&y=1", http.StatusBadRequest},
+		{"x=1.5&y=1", http.StatusBadRequest},
+		{"x=-1&y=0", http.StatusNotFound},
+		{"x=4&y=0", http.StatusNotFound},
+		{"x=0&y=3", http.StatusNotFound},
+		{"x=0&y=0", http.StatusOK},
+		{"x=3&y=2", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(h, http.MethodGet, "/pixel?"+test.query)
+		if w.Code != test.exp {
+			t.Errorf("%s: expected %d got %d", test.query, test.exp, w.Code)
+		}
+	}
+
+	w := serveRequest(h, http.MethodGet, "/pixel?x=3&y=2")
+	var p struct{ X, Y, R, G, B int }
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 3 || p.Y != 2 || p.R != 1 || p.G != 2 || p.B != 65535 {
+		t.Errorf("invalid pixel: %+v", p)
+	}
+}
+
+func TestServeEncoded(t *testing.T) {
+	s := newTestServer()
+	h := s.handler(&renderer{})
+
+	data, err := s.encoded()
+	if err != nil || data != nil {
+		t.Fatalf("expected nothing before the first render, got %d bytes, %v", len(data), err)
+	}
+
+	s.set(img48.New(image.Rect(0, 0, 8, 8), nil))
+	a, err := s.encoded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.encoded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) == 0 || &a[0] != &b[0] {
+		t.Fatal("expected the encoded image to be reused for the same version")
+	}
+
+	s.set(img48.New(image.Rect(0, 0, 16, 16), nil))
+	c, err := s.encoded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &a[0] == &c[0] || s.jpegV != 2 {
+		t.Fatal("expected a new version to be encoded again")
+	}
+
+	w := serveRequest(h, http.MethodGet, "/image")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("invalid content type '%s'", ct)
+	}
+	if w.Body.String() != string(c) {
+		t.Error("expected the latest encoded image to be served")
+	}
+	conf, err := jpeg.DecodeConfig(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Width != 16 || conf.Height != 16 {
+		t.Errorf("expected a 16x16 jpeg got %dx%d", conf.Width, conf.Height)
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	s := newTestServer()
+	srv := httptest.NewServer(s.handler(&renderer{}))
+	defer srv.Close()
+
+	s.set(img48.New(image.Rect(0, 0, 1, 1), nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("invalid content type '%s'", ct)
+	}
+
+	r := bufio.NewReader(res.Body)
+	event := func() string {
+		var lines []string
+		for {
+			l, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	if e := event(); e != "event: render\ndata: 1\n" {
+		t.Fatalf("expected the current version on subscribe, got %q", e)
+	}
+
+	s.set(img48.New(image.Rect(0, 0, 1, 1), nil))
+	if e := event(); e != "event: render\ndata: 2\n" {
+		t.Fatalf("expected the new version, got %q", e)
+	}
+}
@@ -0,0 +1,134 @@
+package phodo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+// toolFunc returns the element to add to a pipeline of which last is the
+// last element and whether it should replace last.
+type toolFunc func(last pipeline.Element) (el pipeline.Element, replace bool)
+
+// writeTool adds the element created by tool to the configured named
+// pipeline in the script.
+//
+// Only the bytes of the new or replaced element are written so comments,
+// variables and includes in the rest of the script are left untouched.
+func writeTool(c Conf, tool toolFunc) error {
+	data, err := os.ReadFile(c.Script)
+	if err != nil {
+		return err
+	}
+
+	d := pipeline.NewDecoder(bytes.NewReader(data), c.vars, c.aliases)
+	root, err := d.Decode(nil)
+	if err != nil {
+		return err
+	}
+
+	name := string(pipeline.NamedPrefix) + c.Pipeline
+	span, args, ok := d.Source(name)
+	if !ok {
+		return fmt.Errorf("pipeline '%s' is not defined in '%s'", c.Pipeline, c.Script)
+	}
+	ne, _ := root.Get(name)
+	pl, ok := ne.Element.(*pipeline.Pipeline)
+	if !ok {
+		return fmt.Errorf("'%s' is not a pipeline", name)
+	}
+
+	els := pl.Elements()
+	var last pipeline.Element
+	if len(els) != 0 && len(els) == len(args) {
+		last = els[len(els)-1]
+	}
+	el, replace := tool(last)
+
+	buf := bytes.NewBuffer(nil)
+	enc := pipeline.NewEncoder(buf, "    ")
+	if err := enc.Element(el); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	// Insert after the last element, on a new line with the same indentation
+	// if it is on a line of its own.
+	at, indent := span.End-1, "    "
+	if len(args) != 0 {
+		l := args[len(args)-1]
+		at = l.End
+		indent = ""
+		i := l.Start
+		for ; i > 0 && (data[i-1] == ' ' || data[i-1] == '\t'); i-- {
+		}
+		if i == 0 || data[i-1] == '\n' {
+			indent = string(data[i:l.Start])
+		}
+	}
+
+	text := strings.TrimSpace(buf.String())
+	text = strings.ReplaceAll(text, "\n", "\n"+indent)
+
+	out := make([]byte, 0, len(data)+len(text)+len(indent)+1)
+	switch {
+	case replace && last != nil:
+		l := args[len(args)-1]
+		out = append(out, data[:l.Start]...)
+		out = append(out, text...)
+		out = append(out, data[l.End:]...)
+	case len(args) != 0 && indent == "":
+		out = append(out, data[:at]...)
+		out = append(out, ' ')
+		out = append(out, text...)
+		out = append(out, data[at:]...)
+	default:
+		out = append(out, data[:at]...)
+		out = append(out, '\n')
+		out = append(out, indent...)
+		out = append(out, text...)
+		if len(args) == 0 {
+			out = append(out, '\n')
+		}
+		out = append(out, data[at:]...)
+	}
+
+	stat, err := os.Stat(c.Script)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(c.Script, out, stat.Mode().Perm())
+}
+
+// copyFile copies the script src to dst, replacing it if it exists.
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(dst, data, stat.Mode().Perm())
+}
+
+// replaceFile atomically replaces the contents of path with data.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	tmp := core.TempFile(path)
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
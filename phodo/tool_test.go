@@ -0,0 +1,86 @@
+package phodo
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element"
+)
+
+func TestWriteTool(t *testing.T) {
+	crop := func(last pipeline.Element) (pipeline.Element, bool) {
+		return element.ToolCrop(last, image.Rect(10, 20, 110, 220))
+	}
+	straighten := func(last pipeline.Element) (pipeline.Element, bool) {
+		return element.ToolStraighten(last, 0, 0, 100, 10)
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		tool   toolFunc
+		exp    string
+	}{
+		{
+			"single line",
+			".main(hflip() vflip())\n",
+			crop,
+			".main(hflip() vflip() crop(10 20 100 200))\n",
+		},
+		{
+			"multi line spaces",
+			".main(\n  hflip()\n  vflip()\n)\n\n.other(hflip())\n",
+			crop,
+			".main(\n  hflip()\n  vflip()\n  crop(10 20 100 200)\n)\n\n.other(hflip())\n",
+		},
+		{
+			"multi line tabs",
+			"// keep\n.main(\n\thflip()\n\tvflip()\n)\n",
+			straighten,
+			"// keep\n.main(\n\thflip()\n\tvflip()\n\tstraighten(-5.71)\n)\n",
+		},
+		{
+			"replace crop",
+			".main(\n    hflip()\n    crop(5 5 1000 1000)\n)\n",
+			crop,
+			".main(\n    hflip()\n    crop(15 25 100 200)\n)\n",
+		},
+		{
+			"replace straighten",
+			".main(hflip() straighten(1.5))\n",
+			straighten,
+			".main(hflip() straighten(-4.21))\n",
+		},
+		{
+			"calc crop",
+			".main(\n    crop(`width / 4` 0 1000 1000)\n)\n",
+			crop,
+			".main(\n    crop(`width / 4` 0 1000 1000)\n    crop(10 20 100 200)\n)\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewConf(nil, nil)
+			c.Pipeline = "main"
+			c.Script = filepath.Join(t.TempDir(), "a.jpg.pho")
+			if err := os.WriteFile(c.Script, []byte(test.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := writeTool(c, test.tool); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(c.Script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.exp {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.exp, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// CheckpointKey is the context key of the Checkpoints in which named
+// pipelines store the image after each of their elements. When a pipeline
+// is run again only the elements after the last checkpoint that is still
+// valid are executed.
+const CheckpointKey = "pipeline.checkpoints"
+
+// checkpointingKey is set while a pipeline is checkpointing so pipelines it
+// runs don't do so as well.
+const checkpointingKey = "pipeline.checkpointing"
+
+// Checkpoints stores the intermediate images of the latest run of each named
+// pipeline along with the cumulative hash of the input and the elements that
+// produced them.
+type Checkpoints interface {
+	// Checkpoint returns a copy of the last image of the pipeline called
+	// name whose hash matches the one at the same index in sums, and that
+	// index.
+	Checkpoint(name string, sums [][]byte) (*img48.Img, int, bool)
+	// SetCheckpoint stores a copy of img as the image after the i-th element
+	// of the pipeline called name and drops all images after it.
+	SetCheckpoint(name string, i int, sum []byte, img *img48.Img)
+}
+
+// Stateful is implemented by elements that change the context, e.g.: by
+// assigning variables or loading fonts. A pipeline never resumes from a
+// checkpoint after such an element as it would then not be run.
+type Stateful interface {
+	Stateful() bool
+}
+
+// IsStateful reports whether running any of els changes the context.
+func IsStateful(els ...Element) bool {
+	for _, e := range els {
+		if s, ok := e.(Stateful); ok && s.Stateful() {
+			return true
+		}
+	}
+	return false
+}
+
+// resumable returns the number of elements of p after which it can resume
+// from a checkpoint, i.e.: those up to the first stateful element.
+func (p *Pipeline) resumable() int {
+	for i, e := range p.line {
+		if IsStateful(e) {
+			return i
+		}
+	}
+	return len(p.line)
+}
+
+// checkpoints returns the store and the cumulative hash after each element
+// of p given input img. Both are nil if p can not be checkpointed.
+func (p *Pipeline) checkpoints(ctx Context, img *img48.Img) (Checkpoints, [][]byte) {
+	store, ok := ctx.Get(CheckpointKey).(Checkpoints)
+	if !ok || p.name == "" || len(p.hashes) != len(p.line) || len(p.line) == 0 {
+		return nil, nil
+	}
+	if on, _ := ctx.Get(checkpointingKey).(bool); on {
+		return nil, nil
+	}
+
+	sum := imageHash(img)
+	sums := make([][]byte, len(p.line))
+	for i, hash := range p.hashes {
+		if hash == nil {
+			return nil, nil
+		}
+		h := sha256.New()
+		h.Write(sum)
+		h.Write(hash.Value())
+		sum = h.Sum(nil)
+		sums[i] = sum
+	}
+
+	return store, sums
+}
+
+// imageHash returns the crc of the pixels in img, crc32 is used as hashing
+// a cryptographic checksum of an image takes longer than most elements.
+func imageHash(img *img48.Img) []byte {
+	h := crc32.NewIEEE()
+	if img == nil {
+		return h.Sum(nil)
+	}
+
+	r := img.Rect
+	dims := make([]byte, 16)
+	binary.LittleEndian.PutUint64(dims, uint64(r.Dx()))
+	binary.LittleEndian.PutUint64(dims[8:], uint64(r.Dy()))
+	h.Write(dims)
+
+	row := make([]byte, 3*2*r.Dx())
+	hashRow := func(pix []uint16) {
+		for i, v := range pix {
+			binary.LittleEndian.PutUint16(row[i*2:], v)
+		}
+		h.Write(row[:len(pix)*2])
+	}
+	for y := 0; y < r.Dy(); y++ {
+		o := y * img.Stride
+		hashRow(img.Pix[o : o+3*r.Dx()])
+		if img.Alpha != nil {
+			hashRow(img.Alpha[o/3 : o/3+r.Dx()])
+		}
+	}
+
+	return h.Sum(nil)
+}
@@ -183,6 +183,10 @@ func (c AnkoCalc) String(img *img48.Img) (string, error) {
 type errReader struct {
 	r   *bufio.Reader
 	err error
+
+	// off is the byte offset of the next rune, last the size of the
+	// previous one.
+	off, last int
 }
 
 func (er *errReader) Err() error { return er.err }
@@ -192,7 +196,8 @@ func (er *errReader) ReadRune() rune {
 		return 0
 	}
 	var run rune
-	run, _, er.err = er.r.ReadRune()
+	run, er.last, er.err = er.r.ReadRune()
+	er.off += er.last
 	return run
 }
 
@@ -201,6 +206,9 @@ func (er *errReader) UnreadRune() {
 		return
 	}
 	er.err = er.r.UnreadRune()
+	if er.err == nil {
+		er.off -= er.last
+	}
 }
 
 var decodables = map[string]Decodable{}
@@ -233,6 +241,7 @@ type entry struct {
 
 	readIndex int
 	line      int
+	span      Span
 	err       error
 	dec       func(*entry) (interface{}, error)
 }
@@ -466,6 +475,9 @@ func NewRoot(env *env.Env) *Root {
 	}
 }
 
+// Span is a range of bytes in a decoded script.
+type Span struct{ Start, End int }
+
 type NamedElement struct {
 	Hash    []byte
 	Name    string
@@ -582,6 +594,8 @@ func (d *Decoder) Decode(cache *Root) (*Root, error) {
 					return el.Element, fmt.Errorf("%s already defined", name)
 				}
 
+				// Defined in an include, its hash is final.
+				p.set(append(p.Value(), el.Hash...))
 				return el.Element, nil
 			}
 
@@ -652,6 +666,24 @@ func (d *Decoder) Decode(cache *Root) (*Root, error) {
 	return root, nil
 }
 
+// Source returns the span of the top-level entry with the given name and
+// the spans of each of its arguments in the decoded script. Entries from
+// included scripts are not found.
+func (d *Decoder) Source(name string) (Span, []Span, bool) {
+	for _, e := range d.state.values {
+		if e.value != name {
+			continue
+		}
+		args := make([]Span, len(e.values))
+		for i, v := range e.values {
+			args[i] = v.span
+		}
+		return e.span, args, true
+	}
+
+	return Span{}, nil, false
+}
+
 func (d *Decoder) decode(calcenv *env.Env, vars map[string]string, includes *[]string) error {
 	if d.state.decoded {
 		return d.state.err
@@ -678,7 +710,16 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 	varbuf := make([]rune, 0, 1)
 	e.line = *line + 1
 
+	// start is the offset of the first rune of the value in buf.
+	start := -1
+	mark := func(at int) {
+		if start < 0 {
+			start = at
+		}
+	}
+
 	for {
+		at := d.r.off
 		r := d.r.ReadRune()
 		space := r == '\r' || r == '\n' || r == '\t' || r == ' '
 		if r == '\n' {
@@ -690,12 +731,15 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 			return e, d.r.Err()
 
 		case r == '"' && !esc && !calc:
+			mark(at)
 			str = !str
 
 		case r == '\\' && !esc:
+			mark(at)
 			esc = true
 
 		case r == '$' && !esc:
+			mark(at)
 			if d.r.ReadRune() != '{' {
 				buf = append(buf, r)
 				d.r.UnreadRune()
@@ -723,6 +767,7 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 			}
 
 		case r == calcOpen && !calc:
+			mark(at)
 			calc = true
 
 		case r == calcClose && calc:
@@ -733,14 +778,17 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 			val := strings.TrimSpace(string(buf))
 			if val == "" {
 				if r == parenClose {
+					e.span.End = d.r.off
 					return e, nil
 				}
 				break
 			}
-			e.values = append(e.values, &entry{env: e.env, value: val, anko: wasCalc, line: e.line})
+			e.values = append(e.values, &entry{env: e.env, value: val, anko: wasCalc, line: e.line, span: Span{start, at}})
 			wasCalc = false
 			buf = buf[:0]
+			start = -1
 			if r == parenClose {
+				e.span.End = d.r.off
 				return e, nil
 			}
 
@@ -750,8 +798,10 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 				val = anonPipeline
 			}
 
-			ne, err := d.entries(&entry{env: e.env, value: val}, depth+1, vars, includes, line)
+			mark(at)
+			ne, err := d.entries(&entry{env: e.env, value: val, span: Span{Start: start}}, depth+1, vars, includes, line)
 			buf = buf[:0]
+			start = -1
 			if err != nil {
 				return e, err
 			}
@@ -763,6 +813,7 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 			f := string(buf)
 			*includes = append(*includes, f)
 			buf = buf[:0]
+			start = -1
 			inc = false
 
 		case d.state.nl && r == '/':
@@ -781,6 +832,7 @@ func (d *Decoder) entries(e *entry, depth int, vars map[string]string, includes
 			}
 
 		default:
+			mark(at)
 			esc = false
 			buf = append(buf, r)
 		}
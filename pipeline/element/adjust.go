@@ -12,8 +12,13 @@ func Contrast(n float64) pipeline.Element   { return contrast{n: pipeline.PlainN
 func ContrastY(n float64) pipeline.Element  { return contrastY{n: pipeline.PlainNumber(n)} }
 func Brightness(n float64) pipeline.Element { return brightness{n: pipeline.PlainNumber(n)} }
 func Gamma(n float64) pipeline.Element      { return gamma{n: pipeline.PlainNumber(n)} }
+func Exposure(n float64) pipeline.Element   { return exposure{n: pipeline.PlainNumber(n)} }
 func Saturation(n float64) pipeline.Element { return saturation{n: pipeline.PlainNumber(n)} }
 func Black(n float64) pipeline.Element      { return black{n: pipeline.PlainNumber(n)} }
+func ExposureRolloff(n, rolloff float64) pipeline.Element {
+	return exposure{n: pipeline.PlainNumber(n), rolloff: pipeline.PlainNumber(rolloff)}
+}
+
 func Eq(ns ...float64) pipeline.Element {
 	l := make([]pipeline.Value, len(ns))
 	for i := range l {
@@ -22,6 +27,99 @@ func Eq(ns ...float64) pipeline.Element {
 	return eq{ns: l}
 }
 
+func ShadowsHighlights(shadows, highlights float64) pipeline.Element {
+	return shadowsHighlights{
+		shadows:    pipeline.PlainNumber(shadows),
+		highlights: pipeline.PlainNumber(highlights),
+	}
+}
+
+func ShadowsHighlightsRadius(shadows, highlights, radius float64) pipeline.Element {
+	return shadowsHighlights{
+		shadows:    pipeline.PlainNumber(shadows),
+		highlights: pipeline.PlainNumber(highlights),
+		radius:     pipeline.PlainNumber(radius),
+	}
+}
+
+func Dehaze(amount float64) pipeline.Element {
+	return dehaze{amount: pipeline.PlainNumber(amount)}
+}
+
+func DehazePatch(amount float64, patch int) pipeline.Element {
+	return dehaze{amount: pipeline.PlainNumber(amount), patch: pipeline.PlainNumber(patch)}
+}
+
+func Vignette(amount, midpoint, roundness, feather float64) pipeline.Element {
+	return vignette{
+		amount:    pipeline.PlainNumber(amount),
+		midpoint:  pipeline.PlainNumber(midpoint),
+		roundness: pipeline.PlainNumber(roundness),
+		feather:   pipeline.PlainNumber(feather),
+	}
+}
+
+func VignetteProtect(amount, midpoint, roundness, feather, highlightProtect float64) pipeline.Element {
+	return vignette{
+		amount:    pipeline.PlainNumber(amount),
+		midpoint:  pipeline.PlainNumber(midpoint),
+		roundness: pipeline.PlainNumber(roundness),
+		feather:   pipeline.PlainNumber(feather),
+		protect:   pipeline.PlainNumber(highlightProtect),
+	}
+}
+
+// HSL adjusts hues, ns is a list of <hue> <hue-shift> <saturation>
+// <luminance> groups.
+func HSL(ns ...float64) pipeline.Element {
+	l := make([]pipeline.Value, len(ns))
+	for i := range l {
+		l[i] = pipeline.PlainNumber(ns[i])
+	}
+	return hsl{ns: l}
+}
+
+func HSLBand(band HueBand, shift, sat, lum float64) pipeline.Element {
+	return hsl{ns: []pipeline.Value{
+		pipeline.PlainString(band),
+		pipeline.PlainNumber(shift),
+		pipeline.PlainNumber(sat),
+		pipeline.PlainNumber(lum),
+	}}
+}
+
+type HueBand string
+
+const (
+	HueRed     HueBand = "red"
+	HueOrange  HueBand = "orange"
+	HueYellow  HueBand = "yellow"
+	HueGreen   HueBand = "green"
+	HueAqua    HueBand = "aqua"
+	HueBlue    HueBand = "blue"
+	HuePurple  HueBand = "purple"
+	HueMagenta HueBand = "magenta"
+)
+
+var hueBands = map[HueBand]float64{
+	HueRed:     0,
+	HueOrange:  30,
+	HueYellow:  60,
+	HueGreen:   120,
+	HueAqua:    180,
+	HueBlue:    240,
+	HuePurple:  270,
+	HueMagenta: 300,
+}
+
+var hueBandsOrder = []HueBand{
+	HueRed, HueOrange, HueYellow, HueGreen, HueAqua, HueBlue, HuePurple, HueMagenta,
+}
+
+func Clarity(amount, radius float64) pipeline.Element {
+	return clarity{amount: pipeline.PlainNumber(amount), radius: pipeline.PlainNumber(radius)}
+}
+
 type contrast struct {
 	n pipeline.Value
 }
@@ -108,6 +206,314 @@ func (c contrastY) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error)
 	return img, nil
 }
 
+type clarity struct {
+	amount pipeline.Value
+	radius pipeline.Value
+}
+
+func (c clarity) Name() string { return "clarity" }
+func (c clarity) Inline() bool { return true }
+
+func (c clarity) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<amount> <radius>)", c.Name()),
+			"Adjusts the local luminance contrast by the given <amount> using",
+		},
+		{
+			"",
+			"a gaussian blur with standard deviation <radius> (e.g.: 50).",
+		},
+	}
+}
+
+func (c clarity) Encode(w pipeline.Writer) error {
+	w.Value(c.amount)
+	w.Value(c.radius)
+	return nil
+}
+
+func (c clarity) Decode(r pipeline.Reader) (interface{}, error) {
+	c.amount = r.Value()
+	c.radius = r.Value()
+	return c, nil
+}
+
+func (c clarity) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(c)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(c.Name())
+	}
+
+	amount, err := c.amount.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	radius, err := pipeline.ScaledFloat64(ctx, c.radius, img)
+	if err != nil {
+		return img, err
+	}
+
+	core.ClarityLuminance(img, amount, radius)
+
+	return img, nil
+}
+
+type shadowsHighlights struct {
+	shadows    pipeline.Value
+	highlights pipeline.Value
+	radius     pipeline.Value
+}
+
+func (s shadowsHighlights) Name() string { return "shadows-highlights" }
+func (s shadowsHighlights) Inline() bool { return true }
+
+func (s shadowsHighlights) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<shadows> <highlights> [radius])", s.Name()),
+			"Lifts the shadows and pulls down the highlights by the given",
+		},
+		{
+			"",
+			"factors (e.g.: 0.5 0.3, negative values do the opposite).",
+		},
+		{
+			"",
+			"Dark and bright areas are determined by a blurred luminance mask",
+		},
+		{
+			"",
+			"with the given [radius] (default: 1% of the largest dimension).",
+		},
+	}
+}
+
+func (s shadowsHighlights) Encode(w pipeline.Writer) error {
+	w.Value(s.shadows)
+	w.Value(s.highlights)
+	if s.radius != nil {
+		w.Value(s.radius)
+	}
+	return nil
+}
+
+func (s shadowsHighlights) Decode(r pipeline.Reader) (interface{}, error) {
+	s.shadows = r.Value()
+	s.highlights = r.Value()
+	if r.Len() > 2 {
+		s.radius = r.Value()
+	}
+	return s, nil
+}
+
+func (s shadowsHighlights) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(s)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(s.Name())
+	}
+
+	shadows, err := s.shadows.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	highlights, err := s.highlights.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	radius := float64(img.Rect.Dx())
+	if h := float64(img.Rect.Dy()); h > radius {
+		radius = h
+	}
+	radius /= 100
+	if s.radius != nil {
+		radius, err = pipeline.ScaledFloat64(ctx, s.radius, img)
+		if err != nil {
+			return img, err
+		}
+	}
+
+	core.ShadowsHighlights(img, shadows, highlights, radius)
+
+	return img, nil
+}
+
+type dehaze struct {
+	amount pipeline.Value
+	patch  pipeline.Value
+}
+
+func (d dehaze) Name() string { return "dehaze" }
+func (d dehaze) Inline() bool { return true }
+
+func (d dehaze) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<amount> [patch-size])", d.Name()),
+			"Removes haze using the dark channel prior. <amount> (0-1) is the",
+		},
+		{
+			"",
+			"fraction of the estimated haze that is removed (e.g.: 0.8).",
+		},
+		{
+			"",
+			"[patch-size] is the size in pixels of the area in which haze is",
+		},
+		{
+			"",
+			"estimated (default: 1% of the largest dimension).",
+		},
+	}
+}
+
+func (d dehaze) Encode(w pipeline.Writer) error {
+	w.Value(d.amount)
+	if d.patch != nil {
+		w.Value(d.patch)
+	}
+	return nil
+}
+
+func (d dehaze) Decode(r pipeline.Reader) (interface{}, error) {
+	d.amount = r.Value()
+	if r.Len() > 1 {
+		d.patch = r.Value()
+	}
+	return d, nil
+}
+
+func (d dehaze) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(d)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(d.Name())
+	}
+
+	amount, err := d.amount.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	patch := img.Rect.Dx()
+	if h := img.Rect.Dy(); h > patch {
+		patch = h
+	}
+	patch /= 100
+	if patch < 3 {
+		patch = 3
+	}
+	if d.patch != nil {
+		patch, err = pipeline.ScaledInt(ctx, d.patch, img)
+		if err != nil {
+			return img, err
+		}
+	}
+
+	core.Dehaze(img, amount, patch)
+
+	return img, nil
+}
+
+type vignette struct {
+	amount    pipeline.Value
+	midpoint  pipeline.Value
+	roundness pipeline.Value
+	feather   pipeline.Value
+	protect   pipeline.Value
+}
+
+func (v vignette) Name() string { return "vignette" }
+func (v vignette) Inline() bool { return true }
+
+func (v vignette) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<amount> <midpoint> <roundness> <feather> [highlight-protect])", v.Name()),
+			"Changes the exposure towards the edges by at most <amount> stops",
+		},
+		{
+			"",
+			"(negative values darken). <midpoint> (0-1) is where half of the",
+		},
+		{
+			"",
+			"effect is reached, 0 being the center and 1 the corners.",
+		},
+		{
+			"",
+			"<roundness> (-1-1) ranges from a rectangle through an ellipse",
+		},
+		{
+			"",
+			"following the aspect ratio (0) to a circle. <feather> (0-1) is the",
+		},
+		{
+			"",
+			"width of the transition. [highlight-protect] (0-1, default 0)",
+		},
+		{
+			"",
+			"reduces the effect on bright areas.",
+		},
+	}
+}
+
+func (v vignette) Encode(w pipeline.Writer) error {
+	w.Value(v.amount)
+	w.Value(v.midpoint)
+	w.Value(v.roundness)
+	w.Value(v.feather)
+	if v.protect != nil {
+		w.Value(v.protect)
+	}
+	return nil
+}
+
+func (v vignette) Decode(r pipeline.Reader) (interface{}, error) {
+	v.amount = r.Value()
+	v.midpoint = r.Value()
+	v.roundness = r.Value()
+	v.feather = r.Value()
+	if r.Len() > 4 {
+		v.protect = r.Value()
+	}
+	return v, nil
+}
+
+func (v vignette) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(v)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(v.Name())
+	}
+
+	var amount, midpoint, roundness, feather, protect float64
+	for _, val := range []struct {
+		v pipeline.Value
+		n *float64
+	}{
+		{v.amount, &amount}, {v.midpoint, &midpoint},
+		{v.roundness, &roundness}, {v.feather, &feather},
+		{v.protect, &protect},
+	} {
+		if val.v == nil {
+			continue
+		}
+		var err error
+		if *val.n, err = val.v.Float64(img); err != nil {
+			return img, err
+		}
+	}
+
+	core.Vignette(img, amount, midpoint, roundness, feather, protect)
+
+	return img, nil
+}
+
 type brightness struct {
 	n pipeline.Value
 }
@@ -194,6 +600,71 @@ func (g gamma) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	return img, nil
 }
 
+type exposure struct {
+	n       pipeline.Value
+	rolloff pipeline.Value
+}
+
+func (e exposure) Name() string { return "exposure" }
+func (e exposure) Inline() bool { return true }
+
+func (e exposure) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<stops> [highlight-rolloff])", e.Name()),
+			"Multiplies the linear light values by 2^<stops> (e.g.: 1 or -0.5).",
+		},
+		{
+			"",
+			"[highlight-rolloff] (0-1, default 0) compresses the brightest part",
+		},
+		{
+			"",
+			"of the range instead of clipping it (e.g.: 0.2).",
+		},
+	}
+}
+
+func (e exposure) Encode(w pipeline.Writer) error {
+	w.Value(e.n)
+	if e.rolloff != nil {
+		w.Value(e.rolloff)
+	}
+	return nil
+}
+
+func (e exposure) Decode(r pipeline.Reader) (interface{}, error) {
+	e.n = r.Value()
+	if r.Len() > 1 {
+		e.rolloff = r.Value()
+	}
+	return e, nil
+}
+
+func (e exposure) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(e)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(e.Name())
+	}
+
+	n, err := e.n.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	var rolloff float64
+	if e.rolloff != nil {
+		rolloff, err = e.rolloff.Float64(img)
+		if err != nil {
+			return img, err
+		}
+	}
+
+	core.Exposure(img, n, rolloff)
+
+	return img, nil
+}
+
 type saturation struct {
 	n pipeline.Value
 }
@@ -280,6 +751,104 @@ func (b black) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	return img, nil
 }
 
+type hsl struct {
+	ns []pipeline.Value
+}
+
+func (h hsl) Name() string { return "hsl" }
+func (h hsl) Inline() bool { return true }
+
+func (h hsl) Help() [][2]string {
+	v := [][2]string{
+		{
+			fmt.Sprintf("%s(<hue> <hue-shift> <sat> <lum> ...)", h.Name()),
+			"Adjusts colors near <hue> (degrees) by rotating their hue by",
+		},
+		{
+			"",
+			"<hue-shift> degrees and changing saturation and luminance by",
+		},
+		{
+			"",
+			fmt.Sprintf("the relative <sat> and <lum> [-1, 1]. Colors within %.0f degrees", core.HueWidth),
+		},
+		{
+			"",
+			"are affected with a smooth falloff. Multiple groups of four can be",
+		},
+		{
+			"",
+			"given. <hue> can also be one of:",
+		},
+	}
+
+	for _, b := range hueBandsOrder {
+		v = append(v, [2]string{"", fmt.Sprintf(" - %-8s (%.0f)", b, hueBands[b])})
+	}
+
+	return v
+}
+
+func (h hsl) Encode(w pipeline.Writer) error {
+	for _, v := range h.ns {
+		w.Value(v)
+	}
+	return nil
+}
+
+func (h hsl) Decode(r pipeline.Reader) (interface{}, error) {
+	n := r.Len()
+	if n%4 != 0 {
+		return h, fmt.Errorf("%s() expects groups of 4 arguments, got %d", h.Name(), n)
+	}
+	l := make([]pipeline.Value, n)
+	for i := range l {
+		l[i] = r.Value()
+	}
+	h.ns = l
+	return h, nil
+}
+
+func (h hsl) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(h)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(h.Name())
+	}
+
+	adj := make([]core.HueAdjustment, len(h.ns)/4)
+	for i := range adj {
+		vs := h.ns[i*4 : i*4+4]
+		str, err := vs[0].String(img)
+		if err != nil {
+			return img, err
+		}
+		hue, ok := hueBands[HueBand(str)]
+		if !ok {
+			hue, err = vs[0].Float64(img)
+			if err != nil {
+				return img, fmt.Errorf("invalid hue '%s'", str)
+			}
+		}
+
+		a := core.HueAdjustment{Hue: hue}
+		if a.Shift, err = vs[1].Float64(img); err != nil {
+			return img, err
+		}
+		if a.Saturation, err = vs[2].Float64(img); err != nil {
+			return img, err
+		}
+		if a.Luminance, err = vs[3].Float64(img); err != nil {
+			return img, err
+		}
+		adj[i] = a
+	}
+
+	core.HSLAdjust(img, adj...)
+
+	return img, nil
+}
+
 type eq struct {
 	ns []pipeline.Value
 }
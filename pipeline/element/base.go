@@ -71,11 +71,11 @@ func (c canvas) Help() [][2]string {
 func (c canvas) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	ctx.Mark(c)
 
-	w, err := c.width.Int(img)
+	w, err := pipeline.ScaledInt(ctx, c.width, img)
 	if err != nil {
 		return img, err
 	}
-	h, err := c.height.Int(img)
+	h, err := pipeline.ScaledInt(ctx, c.height, img)
 	if err != nil {
 		return img, err
 	}
@@ -0,0 +1,154 @@
+package element
+
+import (
+	"fmt"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+func BlurGaussian(sigma float64) pipeline.Element {
+	return blur{box: false, n: pipeline.PlainNumber(sigma)}
+}
+
+func BlurBox(radius int) pipeline.Element {
+	return blur{box: true, n: pipeline.PlainNumber(radius)}
+}
+
+func BlurBilateral(sigmaSpace, sigmaRange float64) pipeline.Element {
+	return blurBilateral{
+		space: pipeline.PlainNumber(sigmaSpace),
+		rng:   pipeline.PlainNumber(sigmaRange),
+	}
+}
+
+type blur struct {
+	box bool
+	n   pipeline.Value
+}
+
+func (b blur) Name() string {
+	if b.box {
+		return "blur-box"
+	}
+
+	return "blur-gaussian"
+}
+
+func (blur) Inline() bool { return true }
+
+func (b blur) Encode(w pipeline.Writer) error {
+	w.Value(b.n)
+	return nil
+}
+
+func (b blur) Decode(r pipeline.Reader) (interface{}, error) {
+	b.n = r.Value()
+	return b, nil
+}
+
+func (b blur) Help() [][2]string {
+	if b.box {
+		return [][2]string{
+			{
+				fmt.Sprintf("%s(<radius>)", b.Name()),
+				"Blurs the image by averaging over a box of 2*<radius>+1 pixels.",
+			},
+		}
+	}
+
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<sigma>)", b.Name()),
+			"Blurs the image with a gaussian kernel with standard deviation",
+		},
+		{
+			"",
+			"<sigma> in pixels.",
+		},
+	}
+}
+
+func (b blur) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(b)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(b.Name())
+	}
+
+	if b.box {
+		radius, err := pipeline.ScaledInt(ctx, b.n, img)
+		if err != nil {
+			return img, err
+		}
+		core.BlurBox(img, radius)
+		return img, nil
+	}
+
+	sigma, err := pipeline.ScaledFloat64(ctx, b.n, img)
+	if err != nil {
+		return img, err
+	}
+	core.BlurGaussian(img, sigma)
+
+	return img, nil
+}
+
+type blurBilateral struct {
+	space pipeline.Value
+	rng   pipeline.Value
+}
+
+func (blurBilateral) Name() string { return "blur-bilateral" }
+func (blurBilateral) Inline() bool { return true }
+
+func (b blurBilateral) Encode(w pipeline.Writer) error {
+	w.Value(b.space)
+	w.Value(b.rng)
+	return nil
+}
+
+func (b blurBilateral) Decode(r pipeline.Reader) (interface{}, error) {
+	b.space = r.Value()
+	b.rng = r.Value()
+	return b, nil
+}
+
+func (b blurBilateral) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<sigma-space> <sigma-range>)", b.Name()),
+			"Edge preserving blur. <sigma-space> is the spatial standard",
+		},
+		{
+			"",
+			"deviation in pixels, <sigma-range> the standard deviation of the",
+		},
+		{
+			"",
+			"color difference [0-1] between neighbouring pixels.",
+		},
+	}
+}
+
+func (b blurBilateral) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(b)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(b.Name())
+	}
+
+	space, err := pipeline.ScaledFloat64(ctx, b.space, img)
+	if err != nil {
+		return img, err
+	}
+	rng, err := b.rng.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	core.BlurBilateral(img, space, rng)
+
+	return img, nil
+}
@@ -127,7 +127,8 @@ type cache struct {
 	p    *pipeline.Pipeline
 }
 
-func (c cache) Name() string { return "cache" }
+func (c cache) Name() string   { return "cache" }
+func (c cache) Stateful() bool { return pipeline.IsStateful(c.p) }
 
 func (c cache) Help() [][2]string {
 	return [][2]string{
@@ -19,7 +19,8 @@ func (c calc) Name() string {
 	}
 	return "calc"
 }
-func (calc) Inline() bool { return true }
+func (calc) Inline() bool   { return true }
+func (calc) Stateful() bool { return true }
 
 func (c calc) Help() [][2]string {
 	if c.print {
@@ -68,8 +69,9 @@ func (c calc) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		}()
 	}
 
+	full := pipeline.FullSize(ctx, img)
 	for i, calc := range c.values {
-		r, err := calc.Value(img)
+		r, err := calc.Value(full)
 		if err != nil {
 			return img, err
 		}
@@ -88,8 +90,9 @@ type set struct {
 	anko     func(string) pipeline.Value
 }
 
-func (set) Name() string { return "set" }
-func (set) Inline() bool { return true }
+func (set) Name() string   { return "set" }
+func (set) Stateful() bool { return true }
+func (set) Inline() bool   { return true }
 
 func (s set) Help() [][2]string {
 	return [][2]string{
@@ -119,13 +122,14 @@ func (s set) Decode(r pipeline.Reader) (interface{}, error) {
 }
 
 func (s set) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
-	variable, err := s.variable.String(img)
+	full := pipeline.FullSize(ctx, img)
+	variable, err := s.variable.String(full)
 	if err != nil {
 		return img, err
 	}
 
 	var exec string
-	val, err := s.value.Value(img)
+	val, err := s.value.Value(full)
 	switch v := val.(type) {
 	case string:
 		exec = fmt.Sprintf("%s = \"%s\"", variable, v)
@@ -133,6 +137,6 @@ func (s set) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		exec = fmt.Sprintf("%s = %v", variable, v)
 	}
 
-	_, err = s.anko(exec).Value(img)
+	_, err = s.anko(exec).Value(full)
 	return img, err
 }
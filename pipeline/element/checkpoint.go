@@ -0,0 +1,117 @@
+package element
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+// CheckpointContainer implements pipeline.Checkpoints. It is separate from
+// the CacheContainer so checkpoints never evict images that were explicitly
+// cached. Only the checkpoints of the latest run of each pipeline are kept,
+// when they exceed max bytes those of the least recently run pipelines and
+// then the earliest of the current one are dropped.
+type CheckpointContainer struct {
+	max    uint64
+	size   uint64
+	chains map[string]*checkpointChain
+}
+
+type checkpointChain struct {
+	access time.Time
+	l      []*cacheEntry
+}
+
+func NewCheckpointContainer(max uint64) *CheckpointContainer {
+	return &CheckpointContainer{max: max, chains: make(map[string]*checkpointChain)}
+}
+
+func (c *CheckpointContainer) Checkpoint(name string, sums [][]byte) (*img48.Img, int, bool) {
+	chain, ok := c.chains[name]
+	if !ok {
+		return nil, 0, false
+	}
+
+	for i := len(sums) - 1; i >= 0; i-- {
+		if i >= len(chain.l) || chain.l[i] == nil {
+			continue
+		}
+		if bytes.Equal(chain.l[i].sum, sums[i]) {
+			chain.access = time.Now()
+			return core.ImageCopyDiscard(chain.l[i].Img), i, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+func (c *CheckpointContainer) SetCheckpoint(name string, i int, sum []byte, img *img48.Img) {
+	chain, ok := c.chains[name]
+	if !ok {
+		chain = &checkpointChain{}
+		c.chains[name] = chain
+	}
+	chain.access = time.Now()
+
+	if i < len(chain.l) {
+		for _, e := range chain.l[i:] {
+			if e != nil {
+				c.size -= e.Size()
+			}
+		}
+		chain.l = chain.l[:i]
+	}
+	for len(chain.l) < i {
+		chain.l = append(chain.l, nil)
+	}
+
+	e := &cacheEntry{access: chain.access, sum: sum, Img: core.ImageCopyDiscard(img)}
+	if e.Size() > c.max {
+		return
+	}
+	chain.l = append(chain.l, e)
+	c.size += e.Size()
+
+	c.cleanup(chain)
+}
+
+// cleanup drops checkpoints until they fit in max, never dropping the
+// latest one of current.
+func (c *CheckpointContainer) cleanup(current *checkpointChain) {
+	for c.size > c.max {
+		var oldest string
+		for name, chain := range c.chains {
+			if chain == current {
+				continue
+			}
+			if oldest == "" || chain.access.Before(c.chains[oldest].access) {
+				oldest = name
+			}
+		}
+
+		if oldest != "" {
+			for _, e := range c.chains[oldest].l {
+				if e != nil {
+					c.size -= e.Size()
+				}
+			}
+			delete(c.chains, oldest)
+			continue
+		}
+
+		for i, e := range current.l[:len(current.l)-1] {
+			if e != nil {
+				c.size -= e.Size()
+				current.l[i] = nil
+				break
+			}
+		}
+	}
+}
+
+func (c *CheckpointContainer) Clear() {
+	c.chains = make(map[string]*checkpointChain)
+	c.size = 0
+}
@@ -28,7 +28,8 @@ type clut struct {
 	interp   pipeline.Value
 }
 
-func (c clut) Name() string { return "clut" }
+func (c clut) Name() string   { return "clut" }
+func (c clut) Stateful() bool { return pipeline.IsStateful(c.e) }
 
 func (c clut) Help() [][2]string {
 	help := [][2]string{
@@ -77,7 +78,11 @@ func (c clut) Decode(r pipeline.Reader) (interface{}, error) {
 }
 
 func (c clut) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	// A CLUT is a lookup table, not an image that should be scaled.
+	scale := pipeline.Scale(ctx)
+	pipeline.SetScale(ctx, 1)
 	clut, err := c.e.Do(ctx, img)
+	pipeline.SetScale(ctx, scale)
 	if err != nil {
 		return img, err
 	}
@@ -91,6 +91,33 @@ func Gamma(img *img48.Img, n float64) {
 	LUT16(img, l)
 }
 
+// Exposure multiplies the linear light values of img by 2^stops. Values
+// above 1-rolloff (0-1) are compressed with a soft shoulder that maps the
+// brightest possible value to white instead of clipping.
+func Exposure(img *img48.Img, stops, rolloff float64) {
+	rolloff = math.Max(0, math.Min(1, rolloff))
+	f := math.Pow(2, stops)
+	knee := 1 - rolloff
+
+	// The shoulder has a slope of 1 at the knee and maps max to 1.
+	var c float64
+	if max := math.Max(f, 1); rolloff != 0 {
+		c = 1/rolloff - 1/(max-knee)
+	}
+
+	l := make([]uint16, 1<<16)
+	for i, v := range toLinear() {
+		v *= f
+		if rolloff != 0 && v > knee {
+			d := v - knee
+			v = knee + d/(1+d*c)
+		}
+		l[i] = fromLinear(v)
+	}
+
+	LUT16(img, l)
+}
+
 func Eq(img *img48.Img, ns ...float64) {
 	if len(ns) == 0 {
 		return
@@ -204,3 +231,58 @@ func InvertFilm(img *img48.Img, r, g, b float64) {
 		}
 	})
 }
+
+// ShadowsHighlights lifts (shadows > 0) or crushes (shadows < 0) dark
+// areas and darkens (highlights > 0) or brightens (highlights < 0) bright
+// areas. Which areas are dark or bright is decided by a gaussian blurred
+// (sigma radius) luminance mask so local contrast is preserved.
+func ShadowsHighlights(img *img48.Img, shadows, highlights, radius float64) {
+	if shadows == 0 && highlights == 0 {
+		return
+	}
+
+	pix := ycbcr(img)
+	var mask []float64
+	if radius > 0 {
+		mask = gaussianBlurChannel(img, pix, 0, radius)
+	}
+
+	const max = float64((1<<16 - 1) << 16)
+	width := img.Rect.Dx()
+	l := width * 3
+	P48y(img, func(offset, y int) {
+		bo := y * width
+		for o_ := 0; o_ < l; o_ += 3 {
+			o := offset + o_
+			yn := float64(pix[o]) / max
+			m := yn
+			if mask != nil {
+				m = mask[bo+o_/3] / max
+			}
+			if m < 0 {
+				m = 0
+			} else if m > 1 {
+				m = 1
+			}
+
+			ws := (1 - m) * (1 - m)
+			wh := m * m
+			exp := math.Pow(2, highlights*wh-shadows*ws)
+			yy := 0
+			if yn > 0 {
+				yy = int(math.Pow(yn, exp) * max)
+			}
+
+			cb := pix[o+1]
+			cr := pix[o+2]
+
+			r := 91881 * cr
+			g := -22554*cb - 46802*cr
+			b := 116130 * cb
+
+			img.Pix[o+0] = intClampUint16((yy + r) >> 16)
+			img.Pix[o+1] = intClampUint16((yy + g) >> 16)
+			img.Pix[o+2] = intClampUint16((yy + b) >> 16)
+		}
+	})
+}
@@ -0,0 +1,309 @@
+package core
+
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// gaussianBoxThreshold is the sigma above which gaussian blurs are
+// approximated by three successive box blurs.
+const gaussianBoxThreshold = 3
+
+func BlurGaussian(img *img48.Img, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	buf := imgFloat(img)
+	gaussianBlurF(img, buf, 3, sigma)
+	floatImg(img, buf)
+}
+
+func BlurBox(img *img48.Img, radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	buf := imgFloat(img)
+	boxBlurF(img, buf, 3, radius)
+	floatImg(img, buf)
+}
+
+// BlurBilateral blurs the image while preserving edges. sigmaSpace is the
+// spatial standard deviation in pixels, sigmaRange the standard deviation
+// of the color distance [0-1].
+func BlurBilateral(img *img48.Img, sigmaSpace, sigmaRange float64) {
+	if sigmaSpace <= 0 || sigmaRange <= 0 {
+		return
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	radius := int(math.Ceil(sigmaSpace * 2))
+	dim := 2*radius + 1
+	spatial := make([]float64, dim*dim)
+	for j := -radius; j <= radius; j++ {
+		for i := -radius; i <= radius; i++ {
+			spatial[(j+radius)*dim+i+radius] = math.Exp(
+				-float64(i*i+j*j) / (2 * sigmaSpace * sigmaSpace),
+			)
+		}
+	}
+
+	// Squared color distances range from 0 to 3.
+	const lutSize = 4096
+	const lutScale = (lutSize - 1) / 3.0
+	rng := make([]float64, lutSize)
+	for i := range rng {
+		d2 := float64(i) / lutScale
+		rng[i] = math.Exp(-d2 / (2 * sigmaRange * sigmaRange))
+	}
+
+	src := imgFloat(img)
+	for i := range src {
+		src[i] /= 1<<16 - 1
+	}
+
+	l := width * 3
+	P48y(img, func(offset, y int) {
+		for x := 0; x < width; x++ {
+			o := y*l + x*3
+			cr, cg, cb := src[o+0], src[o+1], src[o+2]
+			var sr, sg, sb, sw float64
+			for j := -radius; j <= radius; j++ {
+				sy := y + j
+				if sy < 0 || sy >= height {
+					continue
+				}
+				so_ := sy * l
+				ko := (j + radius) * dim
+				for i := -radius; i <= radius; i++ {
+					sx := x + i
+					if sx < 0 || sx >= width {
+						continue
+					}
+					so := so_ + sx*3
+					r, g, b := src[so+0], src[so+1], src[so+2]
+					dr, dg, db := r-cr, g-cg, b-cb
+					d2 := dr*dr + dg*dg + db*db
+					w := spatial[ko+i+radius] * rng[int(d2*lutScale)]
+					sr += r * w
+					sg += g * w
+					sb += b * w
+					sw += w
+				}
+			}
+
+			do := offset + x*3
+			img.Pix[do+0] = floatClampUint16(sr / sw * (1<<16 - 1))
+			img.Pix[do+1] = floatClampUint16(sg / sw * (1<<16 - 1))
+			img.Pix[do+2] = floatClampUint16(sb / sw * (1<<16 - 1))
+		}
+	})
+}
+
+// imgFloat converts the pixels of img to a compact (stride = 3*width)
+// float64 buffer.
+func imgFloat(img *img48.Img) []float64 {
+	l := img.Rect.Dx() * 3
+	buf := make([]float64, l*img.Rect.Dy())
+	P48(img, func(pix []uint16, y int) {
+		row := buf[y*l : (y+1)*l]
+		for i, v := range pix {
+			row[i] = float64(v)
+		}
+	})
+
+	return buf
+}
+
+// floatImg writes a buffer created by imgFloat back to img.
+func floatImg(img *img48.Img, buf []float64) {
+	l := img.Rect.Dx() * 3
+	P48(img, func(pix []uint16, y int) {
+		row := buf[y*l : (y+1)*l]
+		for i, v := range row {
+			pix[i] = floatClampUint16(v + 0.5)
+		}
+	})
+}
+
+// gaussianKernel1D returns a normalized one dimensional gaussian kernel
+// covering 3 sigma on each side.
+func gaussianKernel1D(sigma float64) []float64 {
+	radius := int(math.Ceil(sigma * 3))
+	if radius < 1 {
+		radius = 1
+	}
+
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := -radius; i <= radius; i++ {
+		w := math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		kernel[i+radius] = w
+		sum += w
+	}
+
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// gaussianBoxes returns the radii of n box blurs that approximate a
+// gaussian blur with the given sigma.
+func gaussianBoxes(sigma float64, n int) []int {
+	wIdeal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	wl := int(wIdeal)
+	if wl%2 == 0 {
+		wl--
+	}
+	wu := wl + 2
+
+	mIdeal := (12*sigma*sigma - float64(n*wl*wl) - float64(4*n*wl) - float64(3*n)) /
+		float64(-4*wl-4)
+	m := int(math.Round(mIdeal))
+
+	radii := make([]int, n)
+	for i := range radii {
+		if i < m {
+			radii[i] = (wl - 1) / 2
+			continue
+		}
+		radii[i] = (wu - 1) / 2
+	}
+
+	return radii
+}
+
+// gaussianBlurF blurs buf (width*height*channels, interleaved) in place.
+func gaussianBlurF(img *img48.Img, buf []float64, channels int, sigma float64) {
+	if sigma < gaussianBoxThreshold {
+		convolveF(img, buf, channels, gaussianKernel1D(sigma))
+		return
+	}
+
+	for _, r := range gaussianBoxes(sigma, 3) {
+		boxBlurF(img, buf, channels, r)
+	}
+}
+
+// gaussianBlurChannel blurs channel c of the interleaved 3 channel pix
+// (as returned by ycbcr) and returns the result as a width*height slice.
+func gaussianBlurChannel(img *img48.Img, pix []int, c int, sigma float64) []float64 {
+	width := img.Rect.Dx()
+	out := make([]float64, width*img.Rect.Dy())
+	P48y(img, func(offset, y int) {
+		row := out[y*width : (y+1)*width]
+		for x := range row {
+			row[x] = float64(pix[offset+x*3+c])
+		}
+	})
+
+	gaussianBlurF(img, out, 1, sigma)
+	return out
+}
+
+// convolveF applies the one dimensional kernel horizontally and vertically
+// to buf in place.
+func convolveF(img *img48.Img, buf []float64, channels int, kernel []float64) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	radius := len(kernel) / 2
+	l := width * channels
+	tmp := make([]float64, len(buf))
+
+	P48y(img, func(_, y int) {
+		row := buf[y*l : (y+1)*l]
+		dst := tmp[y*l : (y+1)*l]
+		for x := 0; x < width; x++ {
+			for c := 0; c < channels; c++ {
+				var sum float64
+				for i := -radius; i <= radius; i++ {
+					sx := x + i
+					if sx < 0 {
+						sx = 0
+					} else if sx >= width {
+						sx = width - 1
+					}
+					sum += row[sx*channels+c] * kernel[i+radius]
+				}
+				dst[x*channels+c] = sum
+			}
+		}
+	})
+
+	P48x(img, func(_, x int) {
+		for y := 0; y < height; y++ {
+			for c := 0; c < channels; c++ {
+				var sum float64
+				for j := -radius; j <= radius; j++ {
+					sy := y + j
+					if sy < 0 {
+						sy = 0
+					} else if sy >= height {
+						sy = height - 1
+					}
+					sum += tmp[sy*l+x*channels+c] * kernel[j+radius]
+				}
+				buf[y*l+x*channels+c] = sum
+			}
+		}
+	})
+}
+
+// boxBlurF applies a box blur of 2*radius+1 horizontally and vertically to
+// buf in place using running sums, its cost is independent of radius.
+func boxBlurF(img *img48.Img, buf []float64, channels, radius int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if radius <= 0 || width == 0 || height == 0 {
+		return
+	}
+
+	l := width * channels
+	d := float64(2*radius + 1)
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= max {
+			return max - 1
+		}
+		return v
+	}
+
+	P48y(img, func(_, y int) {
+		row := buf[y*l : (y+1)*l]
+		src := make([]float64, l)
+		copy(src, row)
+		for c := 0; c < channels; c++ {
+			var sum float64
+			for i := -radius; i <= radius; i++ {
+				sum += src[clamp(i, width)*channels+c]
+			}
+			for x := 0; x < width; x++ {
+				row[x*channels+c] = sum / d
+				sum += src[clamp(x+radius+1, width)*channels+c] -
+					src[clamp(x-radius, width)*channels+c]
+			}
+		}
+	})
+
+	P48x(img, func(_, x int) {
+		src := make([]float64, height*channels)
+		for y := 0; y < height; y++ {
+			copy(src[y*channels:(y+1)*channels], buf[y*l+x*channels:])
+		}
+		for c := 0; c < channels; c++ {
+			var sum float64
+			for j := -radius; j <= radius; j++ {
+				sum += src[clamp(j, height)*channels+c]
+			}
+			for y := 0; y < height; y++ {
+				buf[y*l+x*channels+c] = sum / d
+				sum += src[clamp(y+radius+1, height)*channels+c] -
+					src[clamp(y-radius, height)*channels+c]
+			}
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// Dehaze removes haze using the dark channel prior (He et al.).
+//
+// amount (0-1) is the fraction of haze that is removed and patch the size in
+// pixels of the window over which the dark channel is calculated. The
+// estimated transmission map is refined with a guided filter using the
+// luminance as guide.
+func Dehaze(img *img48.Img, amount float64, patch int) {
+	amount = math.Max(0, math.Min(1, amount))
+	if amount == 0 || patch < 1 {
+		return
+	}
+
+	const (
+		max = 1<<16 - 1
+		// Lower bound of the transmission to avoid amplifying noise in
+		// dense haze.
+		t0 = 0.1
+		// Regularization of the guided filter.
+		eps = 1e-3
+	)
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width == 0 || height == 0 {
+		return
+	}
+
+	pix := imgFloat(img)
+	n := width * height
+
+	dark := make([]float64, n)
+	P48y(img, func(_, y int) {
+		row := pix[y*width*3 : (y+1)*width*3]
+		for x := 0; x < width; x++ {
+			o := x * 3
+			dark[y*width+x] = math.Min(row[o], math.Min(row[o+1], row[o+2])) / max
+		}
+	})
+	minFilterF(img, dark, patch/2)
+
+	// Atmospheric light: the average color of the 0.1% haziest pixels.
+	hist := make([]int, 1<<16)
+	for _, v := range dark {
+		hist[int(v*max+0.5)]++
+	}
+	top := n / 1000
+	if top < 1 {
+		top = 1
+	}
+	threshold, count := max, 0
+	for ; threshold > 0; threshold-- {
+		if count += hist[threshold]; count >= top {
+			break
+		}
+	}
+
+	var atm [3]float64
+	count = 0
+	for i, v := range dark {
+		if int(v*max+0.5) < threshold {
+			continue
+		}
+		count++
+		for c := range atm {
+			atm[c] += pix[i*3+c] / max
+		}
+	}
+	for c := range atm {
+		atm[c] = math.Max(atm[c]/float64(count), 1e-3)
+	}
+
+	// Raw transmission from the dark channel of the image normalized by
+	// the atmospheric light.
+	trans := make([]float64, n)
+	P48y(img, func(_, y int) {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			o := i * 3
+			m := math.Inf(1)
+			for c := range atm {
+				m = math.Min(m, pix[o+c]/max/atm[c])
+			}
+			trans[i] = m
+		}
+	})
+	minFilterF(img, trans, patch/2)
+
+	// Guided filter: the transmission is locally approximated as a linear
+	// function of the luminance.
+	luma := ycbcr(img)
+	guide := make([]float64, n*4)
+	P48y(img, func(offset, y int) {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			g := float64(luma[offset+x*3]) / (max << 16)
+			t := 1 - amount*trans[i]
+			o := i * 4
+			guide[o+0] = g
+			guide[o+1] = t
+			guide[o+2] = g * g
+			guide[o+3] = g * t
+		}
+	})
+
+	radius := 4 * patch
+	boxBlurF(img, guide, 4, radius)
+
+	coef := make([]float64, n*2)
+	for i := 0; i < n; i++ {
+		o := i * 4
+		mg, mt := guide[o+0], guide[o+1]
+		a := (guide[o+3] - mg*mt) / (guide[o+2] - mg*mg + eps)
+		coef[i*2+0] = a
+		coef[i*2+1] = mt - a*mg
+	}
+	boxBlurF(img, coef, 2, radius)
+
+	P48y(img, func(offset, y int) {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			g := float64(luma[offset+x*3]) / (max << 16)
+			t := math.Max(coef[i*2]*g+coef[i*2+1], t0)
+			if t > 1 {
+				t = 1
+			}
+
+			o := offset + x*3
+			po := i * 3
+			for c := range atm {
+				v := (pix[po+c]/max-atm[c])/t + atm[c]
+				img.Pix[o+c] = floatClampUint16(v*max + 0.5)
+			}
+		}
+	})
+}
+
+// minFilterF replaces each value in buf (one channel, stride = width) with
+// the minimum of the 2*radius+1 square around it using the van Herk/Gil-Werman
+// algorithm, its cost is independent of radius.
+func minFilterF(img *img48.Img, buf []float64, radius int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if radius <= 0 || width == 0 || height == 0 {
+		return
+	}
+
+	P48y(img, func(_, y int) {
+		row := buf[y*width : (y+1)*width]
+		minFilter1D(row, radius)
+	})
+
+	P48x(img, func(_, x int) {
+		col := make([]float64, height)
+		for y := range col {
+			col[y] = buf[y*width+x]
+		}
+		minFilter1D(col, radius)
+		for y, v := range col {
+			buf[y*width+x] = v
+		}
+	})
+}
+
+func minFilter1D(v []float64, radius int) {
+	n := len(v)
+	k := 2*radius + 1
+
+	// Pad with +Inf so windows at the edges only consider existing values.
+	l := n + 2*radius
+	l += (k - l%k) % k
+	pad := make([]float64, l)
+	for i := range pad {
+		pad[i] = math.Inf(1)
+	}
+	copy(pad[radius:], v)
+
+	prefix := make([]float64, l)
+	suffix := make([]float64, l)
+	for i := 0; i < l; i++ {
+		if i%k == 0 {
+			prefix[i] = pad[i]
+			continue
+		}
+		prefix[i] = math.Min(prefix[i-1], pad[i])
+	}
+	for i := l - 1; i >= 0; i-- {
+		if i%k == k-1 || i == l-1 {
+			suffix[i] = pad[i]
+			continue
+		}
+		suffix[i] = math.Min(suffix[i+1], pad[i])
+	}
+
+	for i := 0; i < n; i++ {
+		// Window in pad is [i, i+k-1].
+		v[i] = math.Min(suffix[i], prefix[i+k-1])
+	}
+}
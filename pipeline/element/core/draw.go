@@ -275,6 +275,11 @@ func Draw(src, dst *img48.Img, p image.Point, blender Blender) {
 
 	w := sr.Dx()
 	dw, dh := dst.Rect.Dx(), dst.Rect.Dy()
+	if src.Alpha != nil || dst.Alpha != nil {
+		drawOver(src, dst, p, w, blender)
+		return
+	}
+
 	P48(src, func(pix []uint16, y int) {
 		ny := y + p.Y
 		if ny < 0 {
@@ -308,6 +313,73 @@ func Draw(src, dst *img48.Img, p image.Point, blender Blender) {
 	})
 }
 
+// drawOver is Draw for images with an alpha channel. The blended color is
+// composited onto dst using Porter-Duff "over", i.e.: where dst is
+// transparent the src color is used as is, where src is transparent dst is
+// left untouched.
+func drawOver(src, dst *img48.Img, p image.Point, w int, blender Blender) {
+	dw, dh := dst.Rect.Dx(), dst.Rect.Dy()
+	das := dst.Stride / 3
+	P48(src, func(pix []uint16, y int) {
+		ny := y + p.Y
+		if ny < 0 || ny >= dh {
+			return
+		}
+
+		var salpha []uint16
+		if src.Alpha != nil {
+			salpha = src.Alpha[y*src.Stride/3:]
+		}
+
+		do_ := ny * dst.Stride
+		for x := 0; x < w; x++ {
+			nx := x + p.X
+			if nx < 0 {
+				continue
+			}
+			if nx >= dw {
+				break
+			}
+
+			sa, da := 1.0, 1.0
+			if salpha != nil {
+				sa = float64(salpha[x]) / (1<<16 - 1)
+			}
+			if dst.Alpha != nil {
+				da = float64(dst.Alpha[ny*das+nx]) / (1<<16 - 1)
+			}
+			if sa == 0 {
+				continue
+			}
+
+			so := x * 3
+			do := do_ + nx*3
+			spix := pix[so : so+3 : so+3]
+			dpix := dst.Pix[do : do+3 : do+3]
+			br, bg, bb := blender(
+				x, y,
+				nx, ny,
+				spix[0], spix[1], spix[2],
+				dpix[0], dpix[1], dpix[2],
+			)
+
+			oa := sa + da*(1-sa)
+			c := func(s, b, d uint16) uint16 {
+				// The blend mode only applies where dst is opaque.
+				sb := (1-da)*float64(s) + da*float64(b)
+				return floatClampUint16((sb*sa + float64(d)*da*(1-sa)) / oa)
+			}
+
+			dpix[0] = c(spix[0], br, dpix[0])
+			dpix[1] = c(spix[1], bg, dpix[1])
+			dpix[2] = c(spix[2], bb, dpix[2])
+			if dst.Alpha != nil {
+				dst.Alpha[ny*das+nx] = floatClampUint16(oa * (1<<16 - 1))
+			}
+		}
+	})
+}
+
 func DrawRectangle(src Color, dst *img48.Img, rect image.Rectangle, width int) {
 	r, g, b := src.Color()
 	clr := []uint16{r, g, b}
@@ -335,6 +407,18 @@ func DrawRectangle(src Color, dst *img48.Img, rect image.Rectangle, width int) {
 	}
 }
 
+func DrawFilledRectangle(src Color, dst *img48.Img, rect image.Rectangle) {
+	rect = rect.Intersect(image.Rect(0, 0, dst.Rect.Dx(), dst.Rect.Dy()))
+	if rect.Empty() {
+		return
+	}
+
+	o := linehorizdrawer(src, dst)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		o(rect.Min.X+dst.Rect.Min.X, rect.Max.X-1+dst.Rect.Min.X, y+dst.Rect.Min.Y)
+	}
+}
+
 func DrawHorizontalLine(src Color, dst *img48.Img, x1, x2, y int) {
 	linehorizdrawer(src, dst)(x1, x2, y)
 }
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// grainReference is the short side in pixels at which a grain size is
+// expressed in actual pixels, larger images get proportionally larger grain.
+const grainReference = 2000
+
+// Grain adds synthetic film grain to img.
+//
+// amount is the standard deviation of the grain in midtones relative to the
+// full range, it fades out towards the shadows and highlights.
+// size is the grain size in pixels at a short side of 2000px.
+// roughness (0-1) mixes in a finer octave of grain.
+// color (0-1) determines how independent the grain is per channel.
+// Identical arguments always produce identical grain for the given seed.
+func Grain(img *img48.Img, amount, size, roughness, color float64, seed uint64) {
+	if amount == 0 {
+		return
+	}
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	short := w
+	if h < short {
+		short = h
+	}
+
+	cell := size * float64(short) / grainReference
+	if cell < 0.5 {
+		cell = 0.5
+	}
+
+	roughness = math.Max(0, math.Min(1, roughness))
+	color = math.Max(0, math.Min(1, color))
+
+	// The octaves are normalized to a deviation of 1 and mixed as
+	// independent variables.
+	norm := amount * (1<<16 - 1) / math.Hypot(1-roughness, roughness)
+	dev1, dev2 := valueNoiseDeviation(cell), valueNoiseDeviation(cell/2)
+
+	noise := func(x, y float64, ch uint64) float64 {
+		n := valueNoise(x/cell, y/cell, seed+ch*4) / dev1
+		if roughness == 0 {
+			return n
+		}
+		n2 := valueNoise(2*x/cell, 2*y/cell, seed+ch*4+1) / dev2
+		return (1-roughness)*n + roughness*n2
+	}
+
+	P48y(img, func(offset, y int) {
+		fy := float64(y)
+		for x := 0; x < w; x++ {
+			o := offset + x*3
+			r, g, b := img.Pix[o+0], img.Pix[o+1], img.Pix[o+2]
+
+			l := (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / (1<<16 - 1)
+			weight := 4 * l * (1 - l)
+			if weight <= 0 {
+				continue
+			}
+
+			fx := float64(x)
+			var nr, ng, nb float64
+			if color != 1 {
+				n := noise(fx, fy, 0) * (1 - color)
+				nr, ng, nb = n, n, n
+			}
+			if color != 0 {
+				nr += noise(fx, fy, 1) * color
+				ng += noise(fx, fy, 2) * color
+				nb += noise(fx, fy, 3) * color
+			}
+
+			d := weight * norm
+			img.Pix[o+0] = floatClampUint16(float64(r) + d*nr)
+			img.Pix[o+1] = floatClampUint16(float64(g) + d*ng)
+			img.Pix[o+2] = floatClampUint16(float64(b) + d*nb)
+		}
+	})
+}
+
+// valueNoiseDeviation returns the standard deviation of valueNoise when
+// sampled every pixel with the given lattice cell size, interpolating between
+// lattice points reduces it.
+func valueNoiseDeviation(cell float64) float64 {
+	const samples = 1024
+	var v float64
+	for x := 0; x < samples; x++ {
+		f := float64(x) / cell
+		t := smoothstep(f - math.Floor(f))
+		v += (1-t)*(1-t) + t*t
+	}
+
+	// Both axes contribute the same factor to the variance.
+	return v / samples
+}
+
+func smoothstep(t float64) float64 { return t * t * (3 - 2*t) }
+
+// valueNoise returns smoothly interpolated noise with a standard deviation
+// of 1 at lattice points.
+func valueNoise(x, y float64, seed uint64) float64 {
+	fx, fy := math.Floor(x), math.Floor(y)
+	ix, iy := int64(fx), int64(fy)
+	tx, ty := smoothstep(x-fx), smoothstep(y-fy)
+
+	n00 := hashNoise(ix, iy, seed)
+	n10 := hashNoise(ix+1, iy, seed)
+	n01 := hashNoise(ix, iy+1, seed)
+	n11 := hashNoise(ix+1, iy+1, seed)
+
+	n0 := n00 + (n10-n00)*tx
+	n1 := n01 + (n11-n01)*tx
+	return n0 + (n1-n0)*ty
+}
+
+// hashNoise returns a deterministic, approximately gaussian value with a
+// standard deviation of 1 for the given lattice point.
+func hashNoise(x, y int64, seed uint64) float64 {
+	v := splitmix(seed ^ splitmix(uint64(x)^splitmix(uint64(y))))
+
+	// Sum of four uniforms in [0, 1) has a variance of 1/3.
+	var sum float64
+	for i := 0; i < 4; i++ {
+		sum += float64(v&0xffff) / (1 << 16)
+		v >>= 16
+	}
+
+	return (sum - 2) * math.Sqrt(3)
+}
+
+func splitmix(v uint64) uint64 {
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ (v >> 30)) * 0xbf58476d1ce4e5b9
+	v = (v ^ (v >> 27)) * 0x94d049bb133111eb
+	return v ^ (v >> 31)
+}
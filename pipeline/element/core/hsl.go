@@ -0,0 +1,148 @@
+package core
+
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// HueAdjustment adjusts all colors within HueWidth degrees of Hue.
+// Shift is in degrees, Saturation and Luminance are relative [-1, 1].
+type HueAdjustment struct {
+	Hue        float64
+	Shift      float64
+	Saturation float64
+	Luminance  float64
+}
+
+// HueWidth is the distance in degrees from a HueAdjustment's hue at which
+// its influence falls off to zero.
+const HueWidth = 45.0
+
+func hueWeight(h, center float64) float64 {
+	d := math.Abs(math.Mod(h-center+540, 360) - 180)
+	if d >= HueWidth {
+		return 0
+	}
+	return 0.5 * (1 + math.Cos(math.Pi*d/HueWidth))
+}
+
+func HSLAdjust(img *img48.Img, adj ...HueAdjustment) {
+	if len(adj) == 0 {
+		return
+	}
+
+	l := img.Rect.Dx() * 3
+	P48(img, func(pix []uint16, _ int) {
+		for o := 0; o < l; o += 3 {
+			h, s, lum := rgbToHSL(pix[o+0], pix[o+1], pix[o+2])
+			if s == 0 {
+				continue
+			}
+
+			var dh, ds, dl float64
+			for _, a := range adj {
+				w := hueWeight(h, a.Hue)
+				if w == 0 {
+					continue
+				}
+				dh += w * a.Shift
+				ds += w * a.Saturation
+				dl += w * a.Luminance
+			}
+
+			h = math.Mod(h+dh+360, 360)
+			s *= 1 + ds
+			if s > 1 {
+				s = 1
+			} else if s < 0 {
+				s = 0
+			}
+
+			// Scale luminance changes by saturation so near greys are
+			// left mostly untouched.
+			lum *= 1 + dl*s
+			if lum > 1 {
+				lum = 1
+			} else if lum < 0 {
+				lum = 0
+			}
+
+			pix[o+0], pix[o+1], pix[o+2] = hslToRGB(h, s, lum)
+		}
+	})
+}
+
+// rgbToHSL returns hue in degrees, saturation and lightness in [0, 1].
+func rgbToHSL(_r, _g, _b uint16) (h, s, l float64) {
+	r := float64(_r) / (1<<16 - 1)
+	g := float64(_g) / (1<<16 - 1)
+	b := float64(_b) / (1<<16 - 1)
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	d := max - min
+	if d == 0 {
+		return 0, 0, l
+	}
+
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+
+	return h * 60, s, l
+}
+
+func hslToRGB(h, s, l float64) (r, g, b uint16) {
+	if s == 0 {
+		v := floatClampUint16(l*(1<<16-1) + 0.5)
+		return v, v, v
+	}
+
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	h /= 360
+
+	c := func(t float64) uint16 {
+		if t < 0 {
+			t++
+		}
+		if t > 1 {
+			t--
+		}
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 0.5:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return floatClampUint16(v*(1<<16-1) + 0.5)
+	}
+
+	return c(h + 1.0/3), c(h), c(h - 1.0/3)
+}
@@ -9,6 +9,7 @@ import (
 	"image/gif"
 	"image/png"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -41,6 +42,10 @@ func ImageCopy(img *img48.Img) *img48.Img {
 	}
 
 	copy(i.Pix, img.Pix)
+	if img.Alpha != nil {
+		i.Alpha = make([]uint16, len(img.Alpha))
+		copy(i.Alpha, img.Alpha)
+	}
 	return i
 }
 
@@ -65,15 +70,37 @@ func ImageCopyDiscard(img *img48.Img) *img48.Img {
 	r.Max.X, r.Max.Y = img.Rect.Dx(), img.Rect.Dy()
 	i := img48.New(r, img.Exif.Clone())
 
+	if img.Alpha != nil {
+		i.Alpha = make([]uint16, r.Dx()*r.Dy())
+	}
+
 	sd := i.Stride
 	P48(img, func(pix []uint16, y int) {
 		do := y * sd
 		dpix := i.Pix[do : do+sd : do+sd]
 		copy(dpix, pix)
+		if i.Alpha != nil {
+			w, so := r.Dx(), y*img.Stride/3
+			copy(i.Alpha[y*w:(y+1)*w], img.Alpha[so:so+w])
+		}
 	})
 	return i
 }
 
+// ImageFlatten returns the image composited onto black if it has an alpha
+// channel, the image itself is returned otherwise.
+func ImageFlatten(img *img48.Img) *img48.Img {
+	if img.Alpha == nil {
+		return img
+	}
+
+	var r image.Rectangle
+	r.Max.X, r.Max.Y = img.Rect.Dx(), img.Rect.Dy()
+	dst := img48.New(r, img.Exif)
+	Draw(img, dst, image.Point{}, nil)
+	return dst
+}
+
 func TempFile(file string) string {
 	stamp := strconv.FormatInt(time.Now().UnixNano(), 36)
 	rnd := make([]byte, 32)
@@ -222,24 +249,38 @@ func ImageEncode(w io.Writer, img *img48.Img, ext string, quality int) error {
 		ie := enc.NewImage()
 
 		ie.SetWidthHeight(img.Rect.Dx(), img.Rect.Dy())
-		ie.SetPixelFormat(2, 3, []int{16, 16, 16})
 		if len(img.Pix) != 3*img.Rect.Dx()*img.Rect.Dy() {
 			img = ImageCopyDiscard(img)
 		}
 
-		err = ie.EncodeImage(img.Pix)
+		pix := img.Pix
+		if img.Alpha == nil {
+			ie.SetPixelFormat(2, 3, []int{16, 16, 16})
+		} else {
+			ie.SetPixelFormat(2, 4, []int{16, 16, 16, 16})
+			// Unassociated alpha.
+			ie.SetTag(tiff.TagExtraSamples, tiff.TagTypeShort, uint16(2))
+			pix = make([]uint16, 0, len(img.Alpha)*4)
+			for i, a := range img.Alpha {
+				o := i * 3
+				pix = append(pix, img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], a)
+			}
+		}
+
+		err = ie.EncodeImage(pix)
 		if mem != nil && err == nil {
 			_, err = w.Write(mem.buf)
 		}
 	case ".png":
 		err = png.Encode(w, img)
 	case ".gif":
-		err = gif.Encode(w, img, nil)
+		err = gif.Encode(w, ImageFlatten(img), nil)
 	case ".bmp":
-		err = bmp.Encode(w, img)
+		err = bmp.Encode(w, ImageFlatten(img))
 	case ".i48":
 		err = img48.Encode(w, img)
 	default:
+		img = ImageFlatten(img)
 		err = jpeg.EncodeWithExif(w, img, img.Exif, quality)
 	}
 
@@ -250,12 +291,19 @@ func ImageFlipHorizontal(img *img48.Img) {
 	w := img.Rect.Dx()
 	s := (w - 1) * 3
 	mid := w / 2 * 3
-	P48(img, func(pix []uint16, _ int) {
+	P48(img, func(pix []uint16, y int) {
+		var alpha []uint16
+		if img.Alpha != nil {
+			alpha = img.Alpha[y*img.Stride/3:]
+		}
 		for o := 0; o < mid; o += 3 {
 			n := s - o
 			pix[o+0], pix[n+0] = pix[n+0], pix[o+0]
 			pix[o+1], pix[n+1] = pix[n+1], pix[o+1]
 			pix[o+2], pix[n+2] = pix[n+2], pix[o+2]
+			if alpha != nil {
+				alpha[o/3], alpha[n/3] = alpha[n/3], alpha[o/3]
+			}
 		}
 	})
 }
@@ -271,6 +319,9 @@ func ImageFlipVertical(img *img48.Img) {
 			img.Pix[o+0], img.Pix[n+0] = img.Pix[n+0], img.Pix[o+0]
 			img.Pix[o+1], img.Pix[n+1] = img.Pix[n+1], img.Pix[o+1]
 			img.Pix[o+2], img.Pix[n+2] = img.Pix[n+2], img.Pix[o+2]
+			if img.Alpha != nil {
+				img.Alpha[o/3], img.Alpha[n/3] = img.Alpha[n/3], img.Alpha[o/3]
+			}
 		}
 	})
 }
@@ -306,6 +357,9 @@ func ImageRotate(img *img48.Img, rotate int) *img48.Img {
 	var r image.Rectangle
 	r.Max.X, r.Max.Y = w, h
 	dst := img48.New(r, img.Exif)
+	if img.Alpha != nil {
+		dst.Alpha = make([]uint16, w*h)
+	}
 
 	l := img.Rect.Dx() * 3
 	P48(img, func(pix []uint16, y int) {
@@ -314,6 +368,9 @@ func ImageRotate(img *img48.Img, rotate int) *img48.Img {
 			dx, dy := norm(x, y)
 			do := dy*dst.Stride + dx*3
 			copy(dst.Pix[do:do+3:do+3], pix[so:so+3:so+3])
+			if dst.Alpha != nil {
+				dst.Alpha[do/3] = img.Alpha[y*img.Stride/3+x]
+			}
 			x++
 		}
 	})
@@ -321,6 +378,81 @@ func ImageRotate(img *img48.Img, rotate int) *img48.Img {
 	return dst
 }
 
+// ImageStraighten rotates the given image by an arbitrary number of degrees
+// clockwise and crops the result to the largest rectangle with the same
+// aspect ratio that only contains image data.
+func ImageStraighten(img *img48.Img, degrees float64) *img48.Img {
+	sw, sh := img.Rect.Dx(), img.Rect.Dy()
+	if degrees == 0 || sw == 0 || sh == 0 {
+		return img
+	}
+
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	as, ac := math.Abs(sin), math.Abs(cos)
+
+	fw, fh := float64(sw), float64(sh)
+	scale := math.Min(fw/(fw*ac+fh*as), fh/(fw*as+fh*ac))
+	w, h := int(fw*scale), int(fh*scale)
+	if w < 1 || h < 1 {
+		w, h = 1, 1
+	}
+
+	var r image.Rectangle
+	r.Max.X, r.Max.Y = w, h
+	dst := img48.New(r, img.Exif)
+	if img.Alpha != nil {
+		dst.Alpha = make([]uint16, w*h)
+	}
+
+	scx, scy := fw/2, fh/2
+	dcx, dcy := float64(w)/2, float64(h)/2
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+
+	P48y(dst, func(offset, y int) {
+		fy := float64(y) + 0.5 - dcy
+		for x := 0; x < w; x++ {
+			fx := float64(x) + 0.5 - dcx
+			// Inverse rotation of the destination pixel center.
+			px := cos*fx + sin*fy + scx - 0.5
+			py := -sin*fx + cos*fy + scy - 0.5
+
+			x0, y0 := int(math.Floor(px)), int(math.Floor(py))
+			tx, ty := px-float64(x0), py-float64(y0)
+			x1, y1 := clamp(x0+1, sw-1), clamp(y0+1, sh-1)
+			x0, y0 = clamp(x0, sw-1), clamp(y0, sh-1)
+
+			o00 := y0*img.Stride + x0*3
+			o10 := y0*img.Stride + x1*3
+			o01 := y1*img.Stride + x0*3
+			o11 := y1*img.Stride + x1*3
+			lerp := func(v00, v10, v01, v11 uint16) uint16 {
+				a := float64(v00) + (float64(v10)-float64(v00))*tx
+				b := float64(v01) + (float64(v11)-float64(v01))*tx
+				return floatClampUint16(a + (b-a)*ty + 0.5)
+			}
+
+			o := offset + x*3
+			for c := 0; c < 3; c++ {
+				dst.Pix[o+c] = lerp(img.Pix[o00+c], img.Pix[o10+c], img.Pix[o01+c], img.Pix[o11+c])
+			}
+			if dst.Alpha != nil {
+				dst.Alpha[o/3] = lerp(img.Alpha[o00/3], img.Alpha[o10/3], img.Alpha[o01/3], img.Alpha[o11/3])
+			}
+		}
+	})
+
+	return dst
+}
+
 func ImageNormalize(i image.Image) *img48.Img {
 	return img48.Normalize(i)
 }
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"math"
+	"sync"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+var (
+	linearOnce sync.Once
+	linearLUT  []float64
+
+	linear16Once sync.Once
+	toLinear16   []uint16
+	toSRGB16     []uint16
+)
+
+// ToLinear converts the sRGB encoded pixels of img to linear light.
+// Note that 16 bits of linear light hold less precision in the shadows.
+func ToLinear(img *img48.Img) {
+	linear16()
+	LUT16(img, toLinear16)
+}
+
+// ToSRGB converts the linear light pixels of img to sRGB.
+func ToSRGB(img *img48.Img) {
+	linear16()
+	LUT16(img, toSRGB16)
+}
+
+func linear16() {
+	linear16Once.Do(func() {
+		toLinear16 = make([]uint16, 1<<16)
+		toSRGB16 = make([]uint16, 1<<16)
+		for i, v := range toLinear() {
+			toLinear16[i] = floatClampUint16(v*(1<<16-1) + 0.5)
+			toSRGB16[i] = fromLinear(float64(i) / (1<<16 - 1))
+		}
+	})
+}
+
+// toLinear returns a lookup table that maps 16-bit sRGB values to linear
+// light in the range 0-1.
+func toLinear() []float64 {
+	linearOnce.Do(func() {
+		linearLUT = make([]float64, 1<<16)
+		for i := range linearLUT {
+			linearLUT[i] = srgbToLinear(float64(i) / (1<<16 - 1))
+		}
+	})
+
+	return linearLUT
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// fromLinear converts linear light in the range 0-1 to a 16-bit sRGB value.
+func fromLinear(v float64) uint16 {
+	return floatClampUint16(linearToSRGB(v)*(1<<16-1) + 0.5)
+}
@@ -1,6 +1,10 @@
 package core
 
-import "github.com/frizinak/phodo/img48"
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
 
 func Sharpen(img *img48.Img) {
 	KernelApply(
@@ -21,3 +25,90 @@ func SharpenLuminance(img *img48.Img) {
 		},
 	)
 }
+
+// UnsharpMaskLuminance sharpens the luminance channel by adding the
+// difference between the image and a gaussian blurred copy of it (with
+// sigma radius), multiplied by amount. Differences smaller than threshold
+// [0-1] are ignored.
+func UnsharpMaskLuminance(img *img48.Img, radius, amount, threshold float64) {
+	if radius <= 0 || amount == 0 {
+		return
+	}
+
+	pix := ycbcr(img)
+	blur := gaussianBlurChannel(img, pix, 0, radius)
+
+	width := img.Rect.Dx()
+	l := width * 3
+	th := threshold * (1<<16 - 1) * (1 << 16)
+	P48y(img, func(offset, y int) {
+		bo := y * width
+		for o_ := 0; o_ < l; o_ += 3 {
+			o := offset + o_
+			yy := pix[o]
+			diff := float64(yy) - blur[bo+o_/3]
+			if math.Abs(diff) >= th {
+				yy += int(diff * amount)
+			}
+
+			cb := pix[o+1]
+			cr := pix[o+2]
+
+			r := 91881 * cr
+			g := -22554*cb - 46802*cr
+			b := 116130 * cb
+
+			img.Pix[o+0] = intClampUint16((yy + r) >> 16)
+			img.Pix[o+1] = intClampUint16((yy + g) >> 16)
+			img.Pix[o+2] = intClampUint16((yy + b) >> 16)
+		}
+	})
+}
+
+// ClarityLuminance enhances local contrast by adding the difference between
+// the luminance and a large radius gaussian blur of it, multiplied by
+// amount. The difference is soft-limited to suppress halos around strong
+// edges and weighted towards the midtones so highlights and shadows are
+// not clipped.
+func ClarityLuminance(img *img48.Img, amount, radius float64) {
+	if radius <= 0 || amount == 0 {
+		return
+	}
+
+	pix := ycbcr(img)
+	blur := gaussianBlurChannel(img, pix, 0, radius)
+
+	const max = float64((1<<16 - 1) << 16)
+	const limit = max / 8
+
+	width := img.Rect.Dx()
+	l := width * 3
+	P48y(img, func(offset, y int) {
+		bo := y * width
+		for o_ := 0; o_ < l; o_ += 3 {
+			o := offset + o_
+			yy := pix[o]
+			diff := float64(yy) - blur[bo+o_/3]
+			diff /= 1 + math.Abs(diff)/limit
+
+			n := 2*float64(yy)/max - 1
+			mid := 1 - n*n
+			if mid < 0 {
+				mid = 0
+			}
+
+			yy += int(diff * amount * mid)
+
+			cb := pix[o+1]
+			cr := pix[o+2]
+
+			r := 91881 * cr
+			g := -22554*cb - 46802*cr
+			b := 116130 * cb
+
+			img.Pix[o+0] = intClampUint16((yy + r) >> 16)
+			img.Pix[o+1] = intClampUint16((yy + g) >> 16)
+			img.Pix[o+2] = intClampUint16((yy + b) >> 16)
+		}
+	})
+}
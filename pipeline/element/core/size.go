@@ -14,6 +14,8 @@ const (
 	ResizeNoUpscale ResizeOptions = 1 << iota
 	ResizeMin
 	ResizeMax
+	// ResizeLinear resamples in linear light.
+	ResizeLinear
 )
 
 func ImageResize(img *img48.Img, kernel draw.Kernel, opts ResizeOptions, w, h int) *img48.Img {
@@ -50,7 +52,16 @@ func ImageResize(img *img48.Img, kernel draw.Kernel, opts ResizeOptions, w, h in
 		sw, sh = nw, nh
 	}
 
-	return cresize(img, image.Rect(0, 0, int(sw), int(sh)), kernel)
+	r := image.Rect(0, 0, int(sw), int(sh))
+	if opts&ResizeLinear == 0 || r.Size() == img.Rect.Size() {
+		return cresize(img, r, kernel)
+	}
+
+	img = ImageCopy(img)
+	ToLinear(img)
+	dst := cresize(img, r, kernel)
+	ToSRGB(dst)
+	return dst
 }
 
 type contrib struct {
@@ -126,6 +137,9 @@ func cresize(src *img48.Img, dstb image.Rectangle, kernel draw.Kernel) *img48.Im
 		var r image.Rectangle
 		r.Max.X, r.Max.Y = maxw, maxh
 		dst = img48.New(r, src.Exif)
+		if src.Alpha != nil {
+			dst.Alpha = make([]uint16, maxw*maxh)
+		}
 	}
 
 	clone := false
@@ -150,6 +164,11 @@ func cresize(src *img48.Img, dstb image.Rectangle, kernel draw.Kernel) *img48.Im
 func wresize(src, dst *img48.Img, sw, dw int, kernel draw.Kernel) {
 	contrib := gcontrib(sw, dw, kernel)
 
+	if src.Alpha != nil {
+		wresizeAlpha(src, dst, contrib)
+		return
+	}
+
 	P48(src, func(pix []uint16, y int) {
 		for x := range contrib {
 			var r, g, b float64
@@ -169,9 +188,37 @@ func wresize(src, dst *img48.Img, sw, dw int, kernel draw.Kernel) {
 	})
 }
 
+// wresizeAlpha is wresize for images with an alpha channel, colors are
+// weighted by their alpha so transparent pixels don't bleed into their
+// neighbours.
+func wresizeAlpha(src, dst *img48.Img, contrib [][]contrib) {
+	P48(src, func(pix []uint16, y int) {
+		alpha := src.Alpha[y*src.Stride/3:]
+		for x := range contrib {
+			var r, g, b, a float64
+			for _, c := range contrib[x] {
+				o := c.i * 3
+				s := pix[o : o+3 : o+3]
+				w := float64(alpha[c.i]) * c.v
+				r += float64(s[0]) * w
+				g += float64(s[1]) * w
+				b += float64(s[2]) * w
+				a += w
+			}
+			o := y*dst.Stride + x*3
+			setAlphaWeighted(dst, o, r, g, b, a)
+		}
+	})
+}
+
 func hresize(src, dst *img48.Img, sh, dh int, kernel draw.Kernel) {
 	contrib := gcontrib(sh, dh, kernel)
 
+	if src.Alpha != nil {
+		hresizeAlpha(src, dst, contrib)
+		return
+	}
+
 	P48x(src, func(offset, x int) {
 		for y := range contrib {
 			var r, g, b float64
@@ -190,3 +237,35 @@ func hresize(src, dst *img48.Img, sh, dh int, kernel draw.Kernel) {
 		}
 	})
 }
+
+func hresizeAlpha(src, dst *img48.Img, contrib [][]contrib) {
+	P48x(src, func(offset, x int) {
+		for y := range contrib {
+			var r, g, b, a float64
+			for _, c := range contrib[y] {
+				o := offset + c.i*src.Stride
+				s := src.Pix[o : o+3 : o+3]
+				w := float64(src.Alpha[o/3]) * c.v
+				r += float64(s[0]) * w
+				g += float64(s[1]) * w
+				b += float64(s[2]) * w
+				a += w
+			}
+			setAlphaWeighted(dst, offset+y*dst.Stride, r, g, b, a)
+		}
+	})
+}
+
+func setAlphaWeighted(dst *img48.Img, o int, r, g, b, a float64) {
+	pix := dst.Pix[o : o+3 : o+3]
+	if a <= 0 {
+		pix[0], pix[1], pix[2] = 0, 0, 0
+		dst.Alpha[o/3] = 0
+		return
+	}
+
+	pix[0] = floatClampUint16(r / a)
+	pix[1] = floatClampUint16(g / a)
+	pix[2] = floatClampUint16(b / a)
+	dst.Alpha[o/3] = floatClampUint16(a)
+}
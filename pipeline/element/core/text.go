@@ -3,7 +3,11 @@ package core
 import (
 	"image"
 	"image/draw"
+	"strings"
+	"sync"
+	"unicode"
 
+	"github.com/frizinak/phodo/img48"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/font/sfnt"
@@ -20,11 +24,7 @@ func FontLoad(d []byte) (*sfnt.Font, error) {
 }
 
 func Text(dst draw.Image, src image.Image, x, y int, size float64, text string, fnt *sfnt.Font) error {
-	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
-		Size:    size,
-		DPI:     72,
-		Hinting: font.HintingNone,
-	})
+	face, err := textFace(fnt, size)
 	if err != nil {
 		return err
 	}
@@ -39,3 +39,362 @@ func Text(dst draw.Image, src image.Image, x, y int, size float64, text string,
 	d.DrawString(text)
 	return nil
 }
+
+type TextAlign uint8
+
+const (
+	TextAlignStart TextAlign = iota
+	TextAlignEnd
+	TextAlignLeft
+	TextAlignCenter
+	TextAlignRight
+)
+
+type TextVAlign uint8
+
+const (
+	TextVAlignTop TextVAlign = iota
+	TextVAlignMiddle
+	TextVAlignBottom
+)
+
+// TextBoxOptions describes a block of text laid out inside Rect.
+// A zero width or height in Rect means the box grows to fit the text in that
+// dimension, Rect.Min is then the anchor used for alignment.
+type TextBoxOptions struct {
+	Rect        image.Rectangle
+	Align       TextAlign
+	VAlign      TextVAlign
+	Size        float64
+	LineSpacing float64
+	Text        string
+	Font        *sfnt.Font
+
+	Color Color
+
+	OutlineWidth int
+	OutlineColor Color
+
+	ShadowOffset image.Point
+	ShadowColor  Color
+}
+
+type textLine struct {
+	text  string
+	width int
+	rtl   bool
+}
+
+type textLayout struct {
+	lines      []textLine
+	width      int
+	lineHeight int
+	ascent     int
+	face       font.Face
+}
+
+func (l textLayout) height() int {
+	if len(l.lines) == 0 {
+		return 0
+	}
+	return l.lineHeight * len(l.lines)
+}
+
+func textFace(fnt *sfnt.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(fnt, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+}
+
+func layoutText(fnt *sfnt.Font, size, lineSpacing float64, text string, maxWidth int) (textLayout, error) {
+	l := textLayout{}
+	face, err := textFace(fnt, size)
+	if err != nil {
+		return l, err
+	}
+	l.face = face
+
+	if lineSpacing <= 0 {
+		lineSpacing = 1
+	}
+	m := face.Metrics()
+	l.ascent = m.Ascent.Ceil()
+	l.lineHeight = int(float64(m.Height.Ceil())*lineSpacing + 0.5)
+
+	measure := func(s string) int { return font.MeasureString(face, s).Ceil() }
+	add := func(s string, rtl bool) {
+		line := textLine{text: s, width: measure(s), rtl: rtl}
+		if line.width > l.width {
+			l.width = line.width
+		}
+		l.lines = append(l.lines, line)
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, para := range strings.Split(text, "\n") {
+		rtl := textRTL(para)
+		if maxWidth <= 0 {
+			add(para, rtl)
+			continue
+		}
+
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			add("", rtl)
+			continue
+		}
+
+		var cur string
+		for _, word := range words {
+			next := word
+			if cur != "" {
+				next = cur + " " + word
+			}
+			if measure(next) <= maxWidth {
+				cur = next
+				continue
+			}
+			if cur != "" {
+				add(cur, rtl)
+			}
+
+			// Break words that don't fit on a line of their own.
+			cur = ""
+			for _, r := range word {
+				next := cur + string(r)
+				if cur != "" && measure(next) > maxWidth {
+					add(cur, rtl)
+					next = string(r)
+				}
+				cur = next
+			}
+		}
+		add(cur, rtl)
+	}
+
+	return l, nil
+}
+
+func runeRTL(r rune) bool {
+	return unicode.In(r, unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko)
+}
+
+// textRTL reports whether the first strongly directional rune in the
+// paragraph s is right-to-left.
+func textRTL(s string) bool {
+	for _, r := range s {
+		if runeRTL(r) {
+			return true
+		}
+		if unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return false
+}
+
+// textVisual reorders a logical line for drawing left to right. Runs of
+// right-to-left runes are reversed and, in a right-to-left line, the order
+// of the runs is reversed as well. Neutral runes join the preceding run.
+// This does not implement the full unicode bidi algorithm nor shaping.
+func textVisual(line textLine) string {
+	type run struct {
+		rtl bool
+		r   []rune
+	}
+
+	var runs []run
+	for _, r := range line.text {
+		rtl := line.rtl
+		switch {
+		case runeRTL(r):
+			rtl = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			rtl = false
+		case len(runs) != 0:
+			rtl = runs[len(runs)-1].rtl
+		}
+
+		if len(runs) == 0 || runs[len(runs)-1].rtl != rtl {
+			runs = append(runs, run{rtl: rtl})
+		}
+		runs[len(runs)-1].r = append(runs[len(runs)-1].r, r)
+	}
+
+	reverse := func(r []rune) {
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+	}
+
+	if line.rtl {
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+	}
+
+	out := make([]rune, 0, len(line.text))
+	for _, r := range runs {
+		if r.rtl {
+			reverse(r.r)
+		}
+		out = append(out, r.r...)
+	}
+
+	return string(out)
+}
+
+// TextMeasure returns the size of the text when laid out with the given
+// options. Lines are wrapped at maxWidth if it is larger than 0.
+func TextMeasure(fnt *sfnt.Font, size, lineSpacing float64, text string, maxWidth int) (w, h int, err error) {
+	l, err := layoutText(fnt, size, lineSpacing, text, maxWidth)
+	return l.width, l.height(), err
+}
+
+// TextBox draws wrapped and aligned text with an optional outline and drop
+// shadow onto dst.
+func TextBox(dst *img48.Img, o TextBoxOptions) error {
+	l, err := layoutText(o.Font, o.Size, o.LineSpacing, o.Text, o.Rect.Dx())
+	if err != nil {
+		return err
+	}
+
+	box := o.Rect
+	if box.Dx() <= 0 {
+		box.Max.X = box.Min.X + l.width
+		switch o.Align {
+		case TextAlignCenter:
+			box = box.Sub(image.Pt(l.width/2, 0))
+		case TextAlignRight:
+			box = box.Sub(image.Pt(l.width, 0))
+		}
+	}
+	if box.Dy() <= 0 {
+		box.Max.Y = box.Min.Y + l.height()
+		switch o.VAlign {
+		case TextVAlignMiddle:
+			box = box.Sub(image.Pt(0, l.height()/2))
+		case TextVAlignBottom:
+			box = box.Sub(image.Pt(0, l.height()))
+		}
+	}
+
+	margin := o.OutlineWidth
+	if margin < 0 {
+		margin = 0
+	}
+	mrect := image.Rect(0, 0, box.Dx()+2*margin, box.Dy()+2*margin)
+	mask := image.NewAlpha16(mrect)
+
+	top := 0
+	switch o.VAlign {
+	case TextVAlignMiddle:
+		top = (box.Dy() - l.height()) / 2
+	case TextVAlignBottom:
+		top = box.Dy() - l.height()
+	}
+
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: l.face}
+	for i, line := range l.lines {
+		align := o.Align
+		switch {
+		case align == TextAlignStart && line.rtl, align == TextAlignEnd && !line.rtl:
+			align = TextAlignRight
+		case align == TextAlignStart, align == TextAlignEnd:
+			align = TextAlignLeft
+		}
+
+		x := 0
+		switch align {
+		case TextAlignCenter:
+			x = (box.Dx() - line.width) / 2
+		case TextAlignRight:
+			x = box.Dx() - line.width
+		}
+
+		y := top + i*l.lineHeight + l.ascent
+		d.Dot = fixed.P(margin+x, margin+y)
+		d.DrawString(textVisual(line))
+	}
+
+	p := box.Min.Sub(image.Pt(margin, margin)).Sub(dst.Rect.Min)
+	if o.ShadowColor != nil && o.ShadowOffset != (image.Point{}) {
+		shadow := mask
+		if o.OutlineWidth > 0 {
+			shadow = maskDilate(mask, o.OutlineWidth)
+		}
+		Draw(maskImage(shadow, o.ShadowColor), dst, p.Add(o.ShadowOffset), nil)
+	}
+	if o.OutlineColor != nil && o.OutlineWidth > 0 {
+		Draw(maskImage(maskDilate(mask, o.OutlineWidth), o.OutlineColor), dst, p, nil)
+	}
+
+	Draw(maskImage(mask, o.Color), dst, p, nil)
+
+	return nil
+}
+
+// maskImage creates a single color image with the given mask as its alpha
+// channel.
+func maskImage(mask *image.Alpha16, clr Color) *img48.Img {
+	r, g, b := clr.Color()
+	var rect image.Rectangle
+	rect.Max.X, rect.Max.Y = mask.Rect.Dx(), mask.Rect.Dy()
+	img := img48.NewAlpha(rect, nil)
+	for i := 0; i < len(img.Pix); i += 3 {
+		img.Pix[i+0], img.Pix[i+1], img.Pix[i+2] = r, g, b
+	}
+	for i := range img.Alpha {
+		img.Alpha[i] = uint16(mask.Pix[2*i])<<8 | uint16(mask.Pix[2*i+1])
+	}
+
+	return img
+}
+
+// maskDilate grows the mask by radius pixels in every direction.
+func maskDilate(mask *image.Alpha16, radius int) *image.Alpha16 {
+	w, h := mask.Rect.Dx(), mask.Rect.Dy()
+	at := func(m *image.Alpha16, x, y int) uint16 {
+		o := y*m.Stride + x*2
+		return uint16(m.Pix[o])<<8 | uint16(m.Pix[o+1])
+	}
+	set := func(m *image.Alpha16, x, y int, v uint16) {
+		o := y*m.Stride + x*2
+		m.Pix[o], m.Pix[o+1] = uint8(v>>8), uint8(v)
+	}
+
+	// Text masks are small, a brute force circular max filter suffices.
+	dst := image.NewAlpha16(mask.Rect)
+	r2 := radius * radius
+	var wg sync.WaitGroup
+	wg.Add(h)
+	for y := 0; y < h; y++ {
+		go func(y int) {
+			defer wg.Done()
+			for x := 0; x < w; x++ {
+				var max uint16
+				for j := -radius; j <= radius && max != 1<<16-1; j++ {
+					sy := y + j
+					if sy < 0 || sy >= h {
+						continue
+					}
+					for i := -radius; i <= radius; i++ {
+						sx := x + i
+						if sx < 0 || sx >= w || i*i+j*j > r2 {
+							continue
+						}
+						if v := at(mask, sx, sy); v > max {
+							max = v
+						}
+					}
+				}
+				set(dst, x, y, max)
+			}
+		}(y)
+	}
+	wg.Wait()
+
+	return dst
+}
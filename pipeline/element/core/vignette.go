@@ -0,0 +1,87 @@
+package core
+
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// Vignette changes the exposure towards the edges of img by amount stops at
+// most (negative values darken).
+//
+// Distances are relative to the image and scaled so a corner is always at
+// sqrt(2), the center of an edge is at 1 for an ellipse.
+// The midpoint (0-1) is the distance, relative to a corner, at which half of
+// the effect is applied and feather (0-1) the width of the transition.
+// The roundness (-1-1) morphs the shape from a rounded rectangle through an
+// ellipse following the aspect ratio to a circle.
+// highlightProtect (0-1) reduces the effect on bright pixels.
+func Vignette(img *img48.Img, amount, midpoint, roundness, feather, highlightProtect float64) {
+	if amount == 0 {
+		return
+	}
+
+	clamp := func(v, min, max float64) float64 { return math.Max(min, math.Min(max, v)) }
+	midpoint = clamp(midpoint, 0, 1)
+	roundness = clamp(roundness, -1, 1)
+	feather = clamp(feather, 0, 1)
+	highlightProtect = clamp(highlightProtect, 0, 1)
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	hw, hh := float64(w)/2, float64(h)/2
+
+	// Circle radius at which the corners lie at the same distance as they do
+	// for the ellipse.
+	cr := math.Hypot(hw, hh) / math.Sqrt2
+
+	// Superellipse exponent, 2 is an ellipse.
+	p := 2.0
+	if roundness < 0 {
+		p = 2 - 6*roundness
+	}
+	// The corner of the superellipse lies at 2^(1/p), scale it to sqrt(2).
+	norm := math.Sqrt2 / math.Pow(2, 1/p)
+
+	mid := midpoint * math.Sqrt2
+	half := feather * math.Sqrt2 / 2
+	lo, hi := mid-half, mid+half
+
+	lin := toLinear()
+	P48y(img, func(offset, y int) {
+		dy := (float64(y) + 0.5 - hh)
+		v := math.Abs(dy / hh)
+		for x := 0; x < w; x++ {
+			dx := (float64(x) + 0.5 - hw)
+			u := math.Abs(dx / hw)
+
+			d := math.Pow(math.Pow(u, p)+math.Pow(v, p), 1/p) * norm
+			if roundness > 0 {
+				d += (math.Hypot(dx, dy)/cr - d) * roundness
+			}
+
+			var t float64
+			switch {
+			case d >= hi:
+				t = 1
+			case d <= lo:
+				continue
+			default:
+				t = smoothstep((d - lo) / (hi - lo))
+			}
+
+			o := offset + x*3
+			r, g, b := lin[img.Pix[o+0]], lin[img.Pix[o+1]], lin[img.Pix[o+2]]
+
+			ev := amount * t
+			if highlightProtect != 0 {
+				l := linearToSRGB(0.2126*r + 0.7152*g + 0.0722*b)
+				ev *= 1 - highlightProtect*smoothstep(clamp(2*l-1, 0, 1))
+			}
+
+			f := math.Pow(2, ev)
+			img.Pix[o+0] = fromLinear(r * f)
+			img.Pix[o+1] = fromLinear(g * f)
+			img.Pix[o+2] = fromLinear(b * f)
+		}
+	})
+}
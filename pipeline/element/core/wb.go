@@ -1,6 +1,10 @@
 package core
 
-import "github.com/frizinak/phodo/img48"
+import (
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
 
 func WhiteBalanceCalc(img *img48.Img, x, y, radius int) (r, g, b float64) {
 	var rs, gs, bs uint64
@@ -49,3 +53,264 @@ func WhiteBalanceCalc(img *img48.Img, x, y, radius int) (r, g, b float64) {
 
 	return
 }
+
+type matrix3 [3][3]float64
+
+func (m matrix3) mul(n matrix3) matrix3 {
+	var r matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m matrix3) apply(v [3]float64) [3]float64 {
+	var r [3]float64
+	for i := range r {
+		r[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return r
+}
+
+var (
+	srgbToXYZ = matrix3{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+	xyzToSRGB = matrix3{
+		{3.2404542, -1.5371385, -0.4985314},
+		{-0.9692660, 1.8760108, 0.0415560},
+		{0.0556434, -0.2040259, 1.0572252},
+	}
+	bradford = matrix3{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	bradfordInv = matrix3{
+		{0.9869929, -0.1470543, 0.1599627},
+		{0.4323053, 0.5183603, 0.0492912},
+		{-0.0085287, 0.0400428, 0.9684867},
+	}
+
+	// whiteD65 is the sRGB reference white.
+	whiteD65 = xyToXYZ(0.3127, 0.3290)
+)
+
+func xyToXYZ(x, y float64) [3]float64 {
+	return [3]float64{x / y, 1, (1 - x - y) / y}
+}
+
+// planckianXY returns the CIE 1931 chromaticity of a black body at the given
+// temperature (clamped to 1667-25000K) using the approximation by Kim et al.
+func planckianXY(kelvin float64) (x, y float64) {
+	t := math.Max(1667, math.Min(25000, kelvin))
+	t2, t3 := t*t, t*t*t
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+
+	x2, x3 := x*x, x*x*x
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+
+	return
+}
+
+// KelvinWhite returns the XYZ (Y = 1) white point of a black body at the
+// given temperature, shifted perpendicular to the Planckian locus by tint.
+// Positive tint values shift towards green, negative ones towards magenta.
+// A tint of 100 corresponds to a Duv of 1/30.
+func KelvinWhite(kelvin, tint float64) [3]float64 {
+	x, y := planckianXY(kelvin)
+	if tint == 0 {
+		return xyToXYZ(x, y)
+	}
+
+	// CIE 1960 UCS.
+	uv := func(x, y float64) (float64, float64) {
+		d := -2*x + 12*y + 3
+		return 4 * x / d, 6 * y / d
+	}
+
+	u, v := uv(x, y)
+	u2, v2 := uv(planckianXY(kelvin * 1.01))
+	du, dv := u2-u, v2-v
+	l := math.Hypot(du, dv)
+	if l == 0 {
+		return xyToXYZ(x, y)
+	}
+
+	// Normal pointing above the locus (green).
+	nu, nv := -dv/l, du/l
+	if nv < 0 {
+		nu, nv = -nu, -nv
+	}
+
+	duv := tint / 3000
+	u, v = u+nu*duv, v+nv*duv
+
+	d := 2*u - 8*v + 4
+	return xyToXYZ(3*u/d, 2*v/d)
+}
+
+// ChromaticAdaptation returns the matrix that converts linear sRGB values
+// so that the from white point maps to the to white point using the
+// Bradford transform.
+func ChromaticAdaptation(from, to [3]float64) [3][3]float64 {
+	cf, ct := bradford.apply(from), bradford.apply(to)
+	var d matrix3
+	for i := range d {
+		d[i][i] = ct[i] / cf[i]
+	}
+
+	return xyzToSRGB.mul(bradfordInv).mul(d).mul(bradford).mul(srgbToXYZ)
+}
+
+// WhiteBalanceKelvin neutralizes the color cast of an illuminant with the
+// given temperature and tint by adapting its white point to D65.
+func WhiteBalanceKelvin(img *img48.Img, kelvin, tint float64) {
+	ColorMatrixLinear(img, ChromaticAdaptation(KelvinWhite(kelvin, tint), whiteD65))
+}
+
+// WhiteBalanceKelvinFrom changes the white balance of an image that was
+// balanced for an illuminant of from kelvin as if it were balanced for one
+// of to kelvin.
+func WhiteBalanceKelvinFrom(img *img48.Img, from, to float64) {
+	ColorMatrixLinear(img, ChromaticAdaptation(KelvinWhite(to, 0), KelvinWhite(from, 0)))
+}
+
+// ColorMatrixLinear multiplies the linear light rgb values of each pixel
+// with m.
+func ColorMatrixLinear(img *img48.Img, m [3][3]float64) {
+	lin := toLinear()
+	l := img.Rect.Dx() * 3
+	P48(img, func(pix []uint16, _ int) {
+		for o := 0; o < l; o += 3 {
+			r, g, b := lin[pix[o+0]], lin[pix[o+1]], lin[pix[o+2]]
+			pix[o+0] = fromLinear(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+			pix[o+1] = fromLinear(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+			pix[o+2] = fromLinear(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+		}
+	})
+}
+
+type WhiteBalanceMethod byte
+
+const (
+	// WhiteBalanceGreyWorld assumes the average color of the image is grey.
+	WhiteBalanceGreyWorld WhiteBalanceMethod = iota
+	// WhiteBalanceWhitePatch assumes the brightest pixels are white.
+	WhiteBalanceWhitePatch
+)
+
+// WhiteBalanceAutoCalc returns linear light channel multipliers that
+// neutralize the color cast of img using the given method. The luminance of
+// the reference color is retained.
+func WhiteBalanceAutoCalc(img *img48.Img, method WhiteBalanceMethod) (r, g, b float64) {
+	lin := toLinear()
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	n := w * h
+	if n == 0 {
+		return 1, 1, 1
+	}
+
+	var ref [3]float64
+	switch method {
+	case WhiteBalanceWhitePatch:
+		// Average of the 1% brightest pixels, ignoring clipped ones which
+		// no longer hold color information.
+		lum := make([]uint16, n)
+		hist := make([]int, 1<<16)
+		P48(img, func(pix []uint16, y int) {
+			for x := 0; x < w; x++ {
+				o := x * 3
+				if pix[o+0] == 1<<16-1 || pix[o+1] == 1<<16-1 || pix[o+2] == 1<<16-1 {
+					continue
+				}
+				lum[y*w+x] = uint16((19595*uint32(pix[o+0]) + 38470*uint32(pix[o+1]) + 7471*uint32(pix[o+2])) >> 16)
+			}
+		})
+		for _, v := range lum {
+			hist[v]++
+		}
+
+		top := n / 100
+		if top < 1 {
+			top = 1
+		}
+		threshold, count := 1<<16-1, 0
+		for ; threshold > 1; threshold-- {
+			if count += hist[threshold]; count >= top {
+				break
+			}
+		}
+
+		count = 0
+		for y := 0; y < h; y++ {
+			pix := img.Pix[y*img.Stride:]
+			for x := 0; x < w; x++ {
+				if int(lum[y*w+x]) < threshold {
+					continue
+				}
+				o := x * 3
+				count++
+				for c := range ref {
+					ref[c] += lin[pix[o+c]]
+				}
+			}
+		}
+		if count == 0 {
+			return 1, 1, 1
+		}
+		for c := range ref {
+			ref[c] /= float64(count)
+		}
+	default:
+		sums := make([][3]float64, h)
+		P48(img, func(pix []uint16, y int) {
+			for o := 0; o < w*3; o += 3 {
+				for c := range ref {
+					sums[y][c] += lin[pix[o+c]]
+				}
+			}
+		})
+		for _, s := range sums {
+			for c := range ref {
+				ref[c] += s[c]
+			}
+		}
+		for c := range ref {
+			ref[c] /= float64(n)
+		}
+	}
+
+	y := 0.2126*ref[0] + 0.7152*ref[1] + 0.0722*ref[2]
+	mul := func(v float64) float64 {
+		if v <= 0 {
+			return 1
+		}
+		return y / v
+	}
+
+	return mul(ref[0]), mul(ref[1]), mul(ref[2])
+}
+
+// WhiteBalanceAuto neutralizes the color cast of img using the given method.
+func WhiteBalanceAuto(img *img48.Img, method WhiteBalanceMethod) {
+	r, g, b := WhiteBalanceAutoCalc(img, method)
+	ColorMatrixLinear(img, [3][3]float64{{r, 0, 0}, {0, g, 0}, {0, 0, b}})
+}
@@ -84,7 +84,7 @@ func (b border) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(b.Name())
 	}
 
-	w, err := b.width.Int(img)
+	w, err := pipeline.ScaledInt(ctx, b.width, img)
 	if err != nil {
 		return img, err
 	}
@@ -139,23 +139,23 @@ func (r rectangle) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error)
 		return img, pipeline.NewErrNeedImageInput(r.Name())
 	}
 
-	x, err := r.x.Int(img)
+	x, err := pipeline.ScaledInt(ctx, r.x, img)
 	if err != nil {
 		return img, err
 	}
-	y, err := r.y.Int(img)
+	y, err := pipeline.ScaledInt(ctx, r.y, img)
 	if err != nil {
 		return img, err
 	}
-	w, err := r.w.Int(img)
+	w, err := pipeline.ScaledInt(ctx, r.w, img)
 	if err != nil {
 		return img, err
 	}
-	h, err := r.h.Int(img)
+	h, err := pipeline.ScaledInt(ctx, r.h, img)
 	if err != nil {
 		return img, err
 	}
-	b, err := r.b.Int(img)
+	b, err := pipeline.ScaledInt(ctx, r.b, img)
 	if err != nil {
 		return img, err
 	}
@@ -217,19 +217,19 @@ func (c circle) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(c.Name())
 	}
 
-	x, err := c.x.Int(img)
+	x, err := pipeline.ScaledInt(ctx, c.x, img)
 	if err != nil {
 		return img, err
 	}
-	y, err := c.y.Int(img)
+	y, err := pipeline.ScaledInt(ctx, c.y, img)
 	if err != nil {
 		return img, err
 	}
-	r, err := c.r.Int(img)
+	r, err := pipeline.ScaledInt(ctx, c.r, img)
 	if err != nil {
 		return img, err
 	}
-	w, err := c.w.Int(img)
+	w, err := pipeline.ScaledInt(ctx, c.w, img)
 	if err != nil {
 		return img, err
 	}
@@ -321,19 +321,19 @@ func (e extend) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(e.Name())
 	}
 
-	top, err := e.top.Int(img)
+	top, err := pipeline.ScaledInt(ctx, e.top, img)
 	if err != nil {
 		return img, err
 	}
-	right, err := e.right.Int(img)
+	right, err := pipeline.ScaledInt(ctx, e.right, img)
 	if err != nil {
 		return img, err
 	}
-	bottom, err := e.bottom.Int(img)
+	bottom, err := pipeline.ScaledInt(ctx, e.bottom, img)
 	if err != nil {
 		return img, err
 	}
-	left, err := e.left.Int(img)
+	left, err := pipeline.ScaledInt(ctx, e.left, img)
 	if err != nil {
 		return img, err
 	}
@@ -343,6 +343,9 @@ func (e extend) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	r.Max.X, r.Max.Y = w+left+right, h+top+bottom
 	p := image.Point{left, top}
 	dst := img48.New(r, img.Exif)
+	if img.Alpha != nil {
+		dst = img48.NewAlpha(r, img.Exif)
+	}
 
 	core.Draw(img, dst, p, nil)
 
@@ -360,8 +363,9 @@ type draw struct {
 	blender   core.Blender
 }
 
-func (draw) Name() string { return "draw" }
-func (draw) Inline() bool { return false }
+func (draw) Name() string     { return "draw" }
+func (d draw) Stateful() bool { return pipeline.IsStateful(d.el) }
+func (draw) Inline() bool     { return false }
 
 func (d draw) Help() [][2]string {
 	v := [][2]string{
@@ -369,23 +373,32 @@ func (d draw) Help() [][2]string {
 			fmt.Sprintf("%s(<x> <y> [blend mode] <src-element>)", d.Name()),
 			"Draws the src-element onto the current image at <x> <y>.",
 		},
+		{
+			"",
+			"Its alpha channel, if any, is respected.",
+		},
 		{
 			"",
 			"<blend mode> can be a number (opacity) or one of:",
 		},
 	}
 
+	for _, k := range blendModeList() {
+		v = append(v, [2]string{"", fmt.Sprintf(" - %s", k)})
+	}
+	return v
+}
+
+// blendModeList returns BlendNone followed by all registered BlendModes in
+// alphabetical order.
+func blendModeList() []string {
 	list := make([]string, 0, len(BlendModes)+1)
 	for k := range BlendModes {
 		list = append(list, string(k))
 	}
 	sort.Strings(list)
 
-	v = append(v, [2]string{"", fmt.Sprintf(" - %s", BlendNone)})
-	for _, k := range list {
-		v = append(v, [2]string{"", fmt.Sprintf(" - %s", k)})
-	}
-	return v
+	return append([]string{string(BlendNone)}, list...)
 }
 
 func (d draw) Encode(w pipeline.Writer) error {
@@ -412,19 +425,9 @@ func (d draw) bl(img *img48.Img) (core.Blender, error) {
 			return blender, err
 		}
 
-		b := BlendNone
-		if v != "" {
-			b = BlendMode(v)
-		}
-
-		_blender, ok := BlendModes[b]
-		if !ok {
-			op, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return blender, fmt.Errorf("invalid blending mode '%s'", b)
-			}
-
-			_blender = core.BlendOpacity(op)
+		_blender, err := parseBlendMode(v)
+		if err != nil || _blender == nil {
+			return blender, err
 		}
 
 		if blender != nil {
@@ -437,14 +440,37 @@ func (d draw) bl(img *img48.Img) (core.Blender, error) {
 	return blender, nil
 }
 
-func (p Point) Value(img *img48.Img) (image.Point, error) {
+// parseBlendMode returns the Blender for the given BlendMode or opacity.
+// A nil Blender is returned for BlendNone.
+func parseBlendMode(v string) (core.Blender, error) {
+	b := BlendNone
+	if v != "" {
+		b = BlendMode(v)
+	}
+	if b == BlendNone {
+		return nil, nil
+	}
+
+	if blender, ok := BlendModes[b]; ok {
+		return blender, nil
+	}
+
+	op, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blending mode '%s'", b)
+	}
+
+	return core.BlendOpacity(op), nil
+}
+
+func (p Point) Value(ctx pipeline.Context, img *img48.Img) (image.Point, error) {
 	var pt image.Point
 	var err error
-	pt.X, err = p.X.Int(img)
+	pt.X, err = pipeline.ScaledInt(ctx, p.X, img)
 	if err != nil {
 		return pt, err
 	}
-	pt.Y, err = p.Y.Int(img)
+	pt.Y, err = pipeline.ScaledInt(ctx, p.Y, img)
 	return pt, err
 }
 
@@ -455,7 +481,7 @@ func (d draw) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(d.Name())
 	}
 
-	pt, err := d.Point.Value(img)
+	pt, err := d.Point.Value(ctx, img)
 	if err != nil {
 		return img, err
 	}
@@ -589,8 +615,9 @@ type drawMask struct {
 	mask pipeline.Element
 }
 
-func (drawMask) Name() string { return "draw-mask" }
-func (drawMask) Inline() bool { return false }
+func (drawMask) Name() string     { return "draw-mask" }
+func (d drawMask) Stateful() bool { return pipeline.IsStateful(d.mask) }
+func (drawMask) Inline() bool     { return false }
 
 func (d drawMask) Help() [][2]string {
 	return [][2]string{
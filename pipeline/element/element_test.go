@@ -5,7 +5,12 @@ import (
 	"context"
 	"errors"
 	"image"
+	"image/color"
+	"image/png"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	ex "github.com/frizinak/phodo/exif"
@@ -99,6 +104,21 @@ var jpeg64x64 = []byte{
 	0xd9,
 }
 
+func pngAlpha64x64() []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{255, uint8(y * 4), 0, uint8(x * 4)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
 func TestZeroAreaImage(t *testing.T) {
 	ex := ex.New()
 	r := image.Rect(0, 0, 0, 0)
@@ -145,6 +165,15 @@ func TestNilImage(t *testing.T) {
 	})
 }
 
+func decodeMain(t *testing.T, script string) pipeline.Element {
+	res, err := pipeline.NewDecoder(bytes.NewReader([]byte(script)), nil, nil).Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, _ := res.Get(".main")
+	return el.Element
+}
+
 func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 	ctx := pipeline.NewContext(0, io.Discard, pipeline.ModeConvert, context.Background())
 	items := pipeline.Registered()
@@ -164,6 +193,8 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 			els = append(els, Load(bytes.NewReader(jpeg0x0)))
 		case rotate:
 			els = append(els, Rotate(1), Rotate(-8))
+		case straighten:
+			els = append(els, Straighten(2.5), Straighten(-30))
 		case clut:
 			els = append(els, CLUT(Load(bytes.NewReader(jpeg64x64)), 0.5, "nearest"))
 			els = append(els, CLUT(Load(bytes.NewReader(jpeg64x64)), 0.5, "trilinear"))
@@ -229,6 +260,12 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				WhiteBalanceSpot(500, 500, -5),
 				WhiteBalanceSpot(500, 500, 0),
 			)
+		case whiteBalance:
+			els = append(els, WhiteBalance(3200, 0), WhiteBalance(7500, 30), WhiteBalance(0, -200))
+		case whiteBalanceFrom:
+			els = append(els, WhiteBalanceFrom(5000, 6500), WhiteBalanceFrom(40000, 1000))
+		case whiteBalanceAuto:
+			els = append(els, WhiteBalanceAuto(WhiteBalanceGreyWorld), WhiteBalanceAuto(WhiteBalanceWhitePatch))
 		case stateElement:
 			els = append(
 				els,
@@ -246,6 +283,8 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 			els = append(els, Brightness(0), Brightness(5.3), Brightness(-1))
 		case gamma:
 			els = append(els, Gamma(0), Gamma(5.3), Gamma(-1))
+		case exposure:
+			els = append(els, Exposure(0), Exposure(1), Exposure(-2.5), ExposureRolloff(1.5, 0.3), ExposureRolloff(-1, 1))
 		case saturation:
 			els = append(els, Saturation(0), Saturation(5.3), Saturation(-1))
 		case black:
@@ -265,6 +304,10 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				Resize(-100, -100, "", core.ResizeNoUpscale),
 				Resize(-100, -100, "", core.ResizeMax),
 				Resize(-100, -100, "", core.ResizeMin),
+				ResizeSharpen(100, 100, "", core.ResizeMax, SharpenScreen),
+				ResizeSharpen(0, 0, "", 0, SharpenPrint),
+				Resize(100, 100, "", core.ResizeMax|core.ResizeLinear),
+				Resize(-100, 0, "", core.ResizeLinear),
 			)
 		case crop:
 			els = append(
@@ -279,6 +322,27 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				els,
 				Draw(50, 50, Load(bytes.NewReader(jpeg64x64)), core.BlendDarken),
 				Draw(-50, 50, Load(bytes.NewReader(jpeg64x64)), nil),
+				Draw(10, 10, Load(bytes.NewReader(pngAlpha64x64())), nil),
+				Draw(10, 10, Load(bytes.NewReader(pngAlpha64x64())), core.BlendScreen),
+			)
+		case watermark:
+			els = append(
+				els,
+				Watermark(AnchorBottomRight, 20, 0.2, 0.5, Load(bytes.NewReader(pngAlpha64x64()))),
+				Watermark(AnchorCenter, 0, 0, 1, Load(bytes.NewReader(jpeg64x64))),
+				Watermark(AnchorTopLeft, -500, 2, 0.8, Load(bytes.NewReader(jpeg64x64))),
+			)
+		case grid:
+			els = append(
+				els,
+				Grid(
+					2, 50, 40, 5, RGB8(255, 255, 255),
+					Load(bytes.NewReader(jpeg64x64)),
+					Load(bytes.NewReader(pngAlpha64x64())),
+					Load(bytes.NewReader(jpeg64x64)),
+				),
+				Grid(3, 100, 100, 0, RGB8(0, 0, 0), Load(bytes.NewReader(jpeg64x64))),
+				Grid(1, 0, 0, 0, RGB8(0, 0, 0)),
 			)
 		case HistogramElement:
 			els = append(
@@ -301,6 +365,25 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				Text(-50, 0, -12, "Wooptee", RGB8(255, 0, 0), FontGoBold),
 				Text(10, 10, 50, "Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee Wooptee", RGB8(255, 0, 0), FontGoBold),
 			)
+		case TextBoxElement:
+			els = append(
+				els,
+				TextBox(10, 10, 200, 100, TextAlignCenter, TextVAlignMiddle, 24, "Wooptee Wooptee\nWooptee", RGB8(255, 0, 0), FontGoBold),
+				TextBox(-50, -50, 0, 0, TextAlignEnd, TextVAlignBottom, 12, "שלום Wooptee", RGB8(255, 0, 0), FontGo).
+					LineSpacing(1.5).
+					Outline(2, RGB8(0, 0, 0)).
+					Shadow(3, 3, RGB8(0, 0, 0)),
+				TextBox(0, 0, 5, 5, TextAlignStart, TextVAlignTop, 50, "WoopteeWooptee Wooptee", RGB8(255, 0, 0), FontGo).
+					Outline(-5, RGB8(0, 0, 0)),
+			)
+		case textMeasure:
+			// Needs the anko environment of a decoder.
+			els = append(
+				els,
+				decodeMain(t, ".main(text-measure(m 100 24 \"Wooptee Wooptee Wooptee\") text-box(0 0 `m_width` `m_height` left top 24 \"Wooptee Wooptee Wooptee\"))"),
+				decodeMain(t, `.main(text-measure(m 0 12 "שלום Wooptee" go-bold 1.5))`),
+				decodeMain(t, `.main(text-measure(m -50 50 "WoopteeWooptee Wooptee"))`),
+			)
 		case ttfFontFile:
 			// ignore
 		case modeOnly:
@@ -308,6 +391,14 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				els,
 				ModeOnly(pipeline.ModeConvert),
 			)
+		case linear:
+			els = append(
+				els,
+				Linear(),
+				Linear(RGBMul(1.2, 1, 0.8, false), Resize(50, 50, "", 0)),
+			)
+		case transfer:
+			els = append(els, ToLinear(), ToSRGB())
 		case denoise:
 			els = append(
 				els,
@@ -315,6 +406,13 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 				//Denoise(3000),
 				DenoiseLuma(1),
 			)
+		case grain:
+			els = append(
+				els,
+				Grain(0.05, 1.5, 0.5),
+				GrainColor(0.2, 0.1, 0, 1),
+				GrainColor(-0.1, 20, 1, 0.3),
+			)
 		case clip:
 			els = append(
 				els,
@@ -329,6 +427,56 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 			els = append(els, InvertFilm(1.38, 1.5, 0.89))
 		case contrastY:
 			els = append(els, ContrastY(1.2))
+		case clarity:
+			els = append(els, Clarity(0.5, 50), Clarity(-1, 5), Clarity(1, 0))
+		case shadowsHighlights:
+			els = append(
+				els,
+				ShadowsHighlights(0.5, 0.3),
+				ShadowsHighlightsRadius(-1, -1, 0),
+				ShadowsHighlightsRadius(1, 1, 80),
+			)
+		case dehaze:
+			els = append(els, Dehaze(0.8), DehazePatch(1, 1), DehazePatch(0.5, 200))
+		case vignette:
+			els = append(
+				els,
+				Vignette(-1, 0.5, 0, 0.5),
+				Vignette(2, 0.2, 1, 0),
+				VignetteProtect(-0.5, 1, -1, 1, 0.8),
+			)
+		case hsl:
+			els = append(
+				els,
+				HSL(),
+				HSL(30, 10, 0.2, -0.1, 220, -5, -1, 1),
+				HSLBand(HueBlue, 0, 0.5, -0.3),
+			)
+		case sharpen:
+			els = append(els, Sharpen(), SharpenOutput(SharpenPrintStrong))
+		case blur:
+			els = append(
+				els,
+				BlurGaussian(1.2),
+				BlurGaussian(25),
+				BlurGaussian(-1),
+				BlurBox(3),
+				BlurBox(0),
+			)
+		case blurBilateral:
+			els = append(
+				els,
+				BlurBilateral(1.5, 0.1),
+				BlurBilateral(0, 0),
+			)
+		case unsharpMask:
+			els = append(
+				els,
+				UnsharpMask(1.5, 0.8, 0.01),
+				UnsharpMask(0, 1, 0),
+				UnsharpMask(-2, 1, 0),
+				UnsharpMask(20, -1, 1),
+			)
 		default:
 			constr = false
 		}
@@ -361,3 +509,303 @@ func testAll(t *testing.T, n func() *img48.Img, onerr func(err error)) {
 		}()
 	}
 }
+
+type plainImage struct{ image.Image }
+
+func TestAlphaRoundTrip(t *testing.T) {
+	r := image.Rect(0, 0, 7, 5)
+	src := img48.New(r, nil)
+	src.Alpha = make([]uint16, r.Dx()*r.Dy())
+	for i := range src.Pix {
+		src.Pix[i] = uint16(i * 1021)
+	}
+	for i := range src.Alpha {
+		src.Alpha[i] = uint16(i * 1873)
+	}
+
+	check := func(t *testing.T, img *img48.Img) {
+		t.Helper()
+		if img.Rect.Dx() != r.Dx() || img.Rect.Dy() != r.Dy() {
+			t.Fatalf("size %s != %s", img.Rect, r)
+		}
+		if img.Alpha == nil {
+			t.Fatal("alpha was dropped")
+		}
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				o := y*src.Stride + x*3
+				do := y*img.Stride + x*3
+				if a, b := src.Alpha[o/3], img.Alpha[do/3]; a != b {
+					t.Fatalf("alpha at %d,%d: %d != %d", x, y, b, a)
+				}
+				for c := 0; c < 3; c++ {
+					if a, b := src.Pix[o+c], img.Pix[do+c]; a != b {
+						t.Fatalf("channel %d at %d,%d: %d != %d", c, x, y, b, a)
+					}
+				}
+			}
+		}
+	}
+
+	for _, ext := range []string{".i48", ".png", ".tif"} {
+		t.Run(ext, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			if err := core.ImageEncode(buf, src, ext, 100); err != nil {
+				t.Fatal(err)
+			}
+			img, err := core.ImageDecode(bytes.NewReader(buf.Bytes()), ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, img)
+		})
+	}
+
+	t.Run("opaque", func(t *testing.T) {
+		opaque := img48.New(r, nil)
+		for _, ext := range []string{".i48", ".png", ".tif"} {
+			buf := bytes.NewBuffer(nil)
+			if err := core.ImageEncode(buf, opaque, ext, 100); err != nil {
+				t.Fatal(err)
+			}
+			img, err := core.ImageDecode(bytes.NewReader(buf.Bytes()), ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Alpha != nil {
+				t.Fatalf("%s: opaque image decoded with alpha", ext)
+			}
+		}
+	})
+
+	t.Run("slow path", func(t *testing.T) {
+		// plainImage has neither a fast path nor an Opaque method so alpha
+		// is only allocated by iCopy once it sees a translucent pixel.
+		n := image.NewNRGBA64(r)
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				n.SetNRGBA64(x, y, src.At(x, y).(color.NRGBA64))
+			}
+		}
+		n.SetNRGBA64(0, 0, color.NRGBA64{1, 2, 3, 1<<16 - 1})
+		src.Pix[0], src.Pix[1], src.Pix[2], src.Alpha[0] = 1, 2, 3, 1<<16-1
+
+		check(t, img48.Normalize(plainImage{n}))
+
+		opaque := image.NewNRGBA64(r)
+		for i := range opaque.Pix {
+			opaque.Pix[i] = 0xff
+		}
+		if img := img48.Normalize(plainImage{opaque}); img.Alpha != nil {
+			t.Fatal("opaque image normalized with alpha")
+		}
+	})
+}
+
+func TestGrain(t *testing.T) {
+	ctx := pipeline.NewContext(0, io.Discard, pipeline.ModeConvert, context.Background())
+	gradient := func() *img48.Img {
+		img := img48.New(image.Rect(0, 0, 256, 128), nil)
+		for i := range img.Pix {
+			img.Pix[i] = uint16((i / 3 % 256) * 256)
+		}
+		return img
+	}
+
+	render := func(el pipeline.Element) *img48.Img {
+		img, err := el.Do(ctx, gradient())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	for _, el := range []pipeline.Element{
+		Grain(0.1, 2, 0),
+		Grain(-0.05, 4, 0.8),
+		GrainColor(0.3, 0.5, 0.2, 1),
+		GrainColor(0.05, 8, 1, 0.5),
+	} {
+		a, b := render(el), render(el)
+		if !equalPix(a, b) {
+			t.Errorf("%+v: two runs produced different grain", el)
+		}
+		if equalPix(a, gradient()) {
+			t.Errorf("%+v: no grain was added", el)
+		}
+	}
+
+	if !equalPix(render(GrainColor(0, 1.5, 0.5, 0.5)), gradient()) {
+		t.Error("an amount of 0 changed the image")
+	}
+}
+
+func equalPix(a, b *img48.Img) bool {
+	if a.Rect != b.Rect || len(a.Pix) != len(b.Pix) {
+		return false
+	}
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestVignetteCorners(t *testing.T) {
+	ctx := pipeline.NewContext(0, io.Discard, pipeline.ModeConvert, context.Background())
+	const gray = 1 << 15
+	for _, roundness := range []float64{-1, -0.5, 0, 0.5, 1} {
+		img := img48.New(image.Rect(0, 0, 400, 200), nil)
+		for i := range img.Pix {
+			img.Pix[i] = gray
+		}
+
+		// Half of the effect is reached at the corners for any roundness.
+		img, err := Vignette(-1, 1, roundness, 0.2).Do(ctx, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		corner := img.Pix[0]
+		center := img.Pix[100*img.Stride+200*3]
+		if center != gray {
+			t.Errorf("roundness %g: center changed to %d", roundness, center)
+		}
+		if corner >= gray*9/10 || corner <= gray/2 {
+			t.Errorf("roundness %g: corner should be darkened about half a stop, got %d", roundness, corner)
+		}
+	}
+}
+
+func TestScaled(t *testing.T) {
+	script := ".main(set(cx `width/2`) calc(`cy = height/2`) crop(0 0 `cx` `cy`))"
+	res, err := pipeline.NewDecoder(bytes.NewReader([]byte(script)), nil, nil).Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, _ := res.Get(".main")
+
+	for _, scale := range []float64{1, 0.5, 0.25} {
+		ctx := pipeline.NewContext(0, io.Discard, pipeline.ModeConvert, context.Background())
+		pipeline.SetScale(ctx, scale)
+
+		w, h := int(400*scale), int(200*scale)
+		img, err := el.Element.Do(ctx, img48.New(image.Rect(0, 0, w, h), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Rect.Dx() != w/2 || img.Rect.Dy() != h/2 {
+			t.Errorf("scale %g: expected %dx%d got %dx%d", scale, w/2, h/2, img.Rect.Dx(), img.Rect.Dy())
+		}
+	}
+}
+
+func TestCheckpointContainer(t *testing.T) {
+	img := func(v uint16) *img48.Img {
+		i := img48.New(image.Rect(0, 0, 2, 2), nil)
+		i.Pix[0] = v
+		return i
+	}
+	size := uint64(len(img(0).Pix) * 2)
+	sums := [][]byte{{1}, {2}, {3}}
+
+	c := NewCheckpointContainer(3 * size)
+	for i, sum := range sums {
+		c.SetCheckpoint(".main", i, sum, img(uint16(i)))
+	}
+	if i, n, ok := c.Checkpoint(".main", sums); !ok || n != 2 || i.Pix[0] != 2 {
+		t.Fatalf("expected the last checkpoint, got %d %t", n, ok)
+	}
+
+	// A new run drops the checkpoints after the element it stored.
+	c.SetCheckpoint(".main", 1, []byte{4}, img(4))
+	if _, n, ok := c.Checkpoint(".main", sums); !ok || n != 0 {
+		t.Fatalf("expected only the first checkpoint to match, got %d %t", n, ok)
+	}
+	if c.size != 2*size {
+		t.Fatalf("expected a size of %d, got %d", 2*size, c.size)
+	}
+
+	// Other pipelines are dropped first, then the earliest checkpoints.
+	c.SetCheckpoint(".other", 0, []byte{5}, img(5))
+	c.SetCheckpoint(".other", 1, []byte{6}, img(6))
+	if _, _, ok := c.Checkpoint(".main", sums); ok {
+		t.Fatal("expected the checkpoints of .main to be dropped")
+	}
+	c.SetCheckpoint(".other", 2, []byte{7}, img(7))
+	c.SetCheckpoint(".other", 3, []byte{8}, img(8))
+	other := [][]byte{{5}, {6}, {7}, {8}}
+	if _, n, ok := c.Checkpoint(".other", other[:1]); ok {
+		t.Fatalf("expected the first checkpoint to be dropped, got %d", n)
+	}
+	if _, n, ok := c.Checkpoint(".other", other); !ok || n != 3 {
+		t.Fatalf("expected the last checkpoint, got %d %t", n, ok)
+	}
+	if c.size > 3*size {
+		t.Fatalf("size %d exceeds the maximum of %d", c.size, 3*size)
+	}
+}
+
+func TestCacheNested(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clut.jpg")
+	if err := os.WriteFile(file, jpeg64x64, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// clut passes its input to the nested element, which should not affect
+	// the cached result as load-file ignores it.
+	ctx := pipeline.NewContext(0, io.Discard, pipeline.ModeEdit, context.Background())
+	run := func(script string) error {
+		img := img48.New(image.Rect(0, 0, 8, 8), nil)
+		_, err := decodeMain(t, script).Do(ctx, img)
+		return err
+	}
+
+	clut := `clut(cache(load-file("` + file + `")))`
+	if err := run(".main(" + clut + ")"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(".main(invert() " + clut + ")"); err != nil {
+		t.Fatalf("expected a cache hit after changing an upstream element: %s", err)
+	}
+}
+
+func TestToolCombine(t *testing.T) {
+	type result struct {
+		el      pipeline.Element
+		replace bool
+	}
+	ret := func(el pipeline.Element, replace bool) result { return result{el, replace} }
+
+	calc := decodeMain(t, ".main(crop(`width / 4` 0 100 100))").(*pipeline.Pipeline).Elements()[0]
+	rect := image.Rect(10, 20, 60, 50)
+	tests := []struct {
+		name string
+		got  result
+		exp  result
+	}{
+		{"crop", ret(ToolCrop(nil, rect)), result{Crop(10, 20, 50, 30), false}},
+		{"crop canon", ret(ToolCrop(nil, image.Rectangle{rect.Max, rect.Min})), result{Crop(10, 20, 50, 30), false}},
+		{"crop offset", ret(ToolCrop(Crop(5, 7, 100, 100), rect)), result{Crop(15, 27, 50, 30), true}},
+		{"crop calc", ret(ToolCrop(calc, rect)), result{Crop(10, 20, 50, 30), false}},
+		{"crop negative", ret(ToolCrop(Crop(5, 7, -10, 100), rect)), result{Crop(10, 20, 50, 30), false}},
+		{"crop other", ret(ToolCrop(HFlip(), rect)), result{Crop(10, 20, 50, 30), false}},
+		{"straighten", ret(ToolStraighten(nil, 0, 0, 100, 10)), result{Straighten(-5.71), false}},
+		{"straighten vertical", ret(ToolStraighten(nil, 0, 0, 10, -100)), result{Straighten(-5.71), false}},
+		{"straighten subtract", ret(ToolStraighten(Straighten(1.5), 0, 0, 100, 10)), result{Straighten(-4.21), true}},
+		{"straighten level", ret(ToolStraighten(Straighten(2), 0, 0, 100, 0)), result{Straighten(2), true}},
+	}
+
+	for _, test := range tests {
+		if test.got.replace != test.exp.replace {
+			t.Errorf("%s: expected replace %t", test.name, test.exp.replace)
+		}
+		if !reflect.DeepEqual(test.got.el, test.exp.el) {
+			t.Errorf("%s: expected %#v got %#v", test.name, test.exp.el, test.got.el)
+		}
+	}
+}
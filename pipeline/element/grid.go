@@ -0,0 +1,173 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+func Grid(columns, cellWidth, cellHeight, gap int, bg pipeline.ComplexValue, cells ...pipeline.Element) pipeline.Element {
+	return grid{
+		columns: pipeline.PlainNumber(columns),
+		w:       pipeline.PlainNumber(cellWidth),
+		h:       pipeline.PlainNumber(cellHeight),
+		gap:     pipeline.PlainNumber(gap),
+		bg:      bg,
+		cells:   cells,
+	}
+}
+
+type grid struct {
+	columns pipeline.Value
+	w, h    pipeline.Value
+	gap     pipeline.Value
+	bg      pipeline.ComplexValue
+	cells   []pipeline.Element
+}
+
+func (grid) Name() string     { return "grid" }
+func (g grid) Stateful() bool { return pipeline.IsStateful(g.cells...) }
+func (grid) Inline() bool     { return false }
+
+func (g grid) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<columns> <cell-w> <cell-h> <gap> <bg-color> [element1] ...[elementN])", g.Name()),
+			"Creates a new image with the result of each element laid out",
+		},
+		{
+			"",
+			"in a grid of <columns> columns. Each cell is <cell-w> by <cell-h>",
+		},
+		{
+			"",
+			"pixels, results that are larger are scaled down to fit.",
+		},
+		{
+			"",
+			"Every element receives a copy of the current image.",
+		},
+	}
+}
+
+func (g grid) Encode(w pipeline.Writer) error {
+	w.Value(g.columns)
+	w.Value(g.w)
+	w.Value(g.h)
+	w.Value(g.gap)
+	if err := w.ComplexValue(g.bg); err != nil {
+		return err
+	}
+	for _, el := range g.cells {
+		if err := w.Element(el); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g grid) Decode(r pipeline.Reader) (interface{}, error) {
+	g.columns = r.Value()
+	g.w = r.Value()
+	g.h = r.Value()
+	g.gap = r.Value()
+	g.bg = r.ComplexValueDefault(RGB16(0, 0, 0))
+	n := r.Len() - 5
+	if n < 0 {
+		n = 0
+	}
+	g.cells = make([]pipeline.Element, n)
+	for i := range g.cells {
+		g.cells[i] = r.Element()
+	}
+
+	return g, nil
+}
+
+func (g grid) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(g)
+
+	columns, err := g.columns.Int(img)
+	if err != nil {
+		return img, err
+	}
+	cw, err := pipeline.ScaledInt(ctx, g.w, img)
+	if err != nil {
+		return img, err
+	}
+	ch, err := pipeline.ScaledInt(ctx, g.h, img)
+	if err != nil {
+		return img, err
+	}
+	gap, err := pipeline.ScaledInt(ctx, g.gap, img)
+	if err != nil {
+		return img, err
+	}
+	_clr, err := g.bg.Value(img)
+	if err != nil {
+		return img, err
+	}
+	bg, ok := _clr.(core.Color)
+	if !ok {
+		return img, fmt.Errorf("element of type '%T' is not a Color", _clr)
+	}
+
+	if columns <= 0 {
+		return img, errors.New("grid needs at least one column")
+	}
+	if cw < 0 || ch < 0 || gap < 0 {
+		return img, errors.New("grid cell size and gap can not be negative")
+	}
+
+	rows := (len(g.cells) + columns - 1) / columns
+	cols := columns
+	if rows <= 1 {
+		cols = len(g.cells)
+	}
+
+	var r image.Rectangle
+	r.Max.X = cols*cw + (cols+1)*gap
+	r.Max.Y = rows*ch + (rows+1)*gap
+	dst := img48.New(r, nil)
+	core.DrawFilledRectangle(bg, dst, r)
+
+	for i, el := range g.cells {
+		in := img
+		if in != nil {
+			in = core.ImageCopy(in)
+		}
+
+		src, err := el.Do(ctx, in)
+		if err != nil {
+			return img, err
+		}
+		if src == nil {
+			continue
+		}
+
+		if src.Rect.Dx() > cw || src.Rect.Dy() > ch {
+			src = core.ImageResize(
+				src,
+				kernels[KernelBox],
+				core.ResizeMax|core.ResizeNoUpscale,
+				cw,
+				ch,
+			)
+		}
+
+		col, row := i%columns, i/columns
+		pt := image.Point{
+			gap + col*(cw+gap) + (cw-src.Rect.Dx())/2,
+			gap + row*(ch+gap) + (ch-src.Rect.Dy())/2,
+		}
+
+		core.Draw(src, dst, pt, nil)
+	}
+
+	return dst, nil
+}
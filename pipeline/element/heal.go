@@ -65,27 +65,27 @@ func (spot healSpot) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error
 		return img, pipeline.NewErrNeedImageInput(spot.Name())
 	}
 
-	x1, err := spot.x1.Int(img)
+	x1, err := pipeline.ScaledInt(ctx, spot.x1, img)
 	if err != nil {
 		return img, err
 	}
-	y1, err := spot.y1.Int(img)
+	y1, err := pipeline.ScaledInt(ctx, spot.y1, img)
 	if err != nil {
 		return img, err
 	}
-	x2, err := spot.x2.Int(img)
+	x2, err := pipeline.ScaledInt(ctx, spot.x2, img)
 	if err != nil {
 		return img, err
 	}
-	y2, err := spot.y2.Int(img)
+	y2, err := pipeline.ScaledInt(ctx, spot.y2, img)
 	if err != nil {
 		return img, err
 	}
-	r, err := spot.r.Int(img)
+	r, err := pipeline.ScaledInt(ctx, spot.r, img)
 	if err != nil {
 		return img, err
 	}
-	ir, err := spot.ir.Int(img)
+	ir, err := pipeline.ScaledInt(ctx, spot.ir, img)
 	if err != nil {
 		return img, err
 	}
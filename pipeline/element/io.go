@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -57,6 +58,10 @@ func (l loader) Help() [][2]string {
 			"",
 			"<quality> [0-100]",
 		},
+		{
+			"",
+			"Previews receive a scaled down version of the image.",
+		},
 	}
 }
 
@@ -105,6 +110,12 @@ func (l loader) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, err
 	}
 
+	if s := pipeline.Scale(ctx); s < 1 {
+		w := int(math.Max(1, math.Round(float64(i.Rect.Dx())*s)))
+		h := int(math.Max(1, math.Round(float64(i.Rect.Dy())*s)))
+		i = core.ImageResize(i, kernels[KernelBox], 0, w, h)
+	}
+
 	return i, nil
 }
 
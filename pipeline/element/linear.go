@@ -0,0 +1,116 @@
+package element
+
+import (
+	"fmt"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+func Linear(els ...pipeline.Element) pipeline.Element {
+	return linear{p: pipeline.New(els...)}
+}
+
+func ToLinear() pipeline.Element { return transfer{linear: true} }
+func ToSRGB() pipeline.Element   { return transfer{linear: false} }
+
+type linear struct {
+	p *pipeline.Pipeline
+}
+
+func (linear) Name() string     { return "linear" }
+func (l linear) Stateful() bool { return pipeline.IsStateful(l.p) }
+func (linear) Inline() bool     { return false }
+
+func (l linear) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s([element1] [element2] ...[elementN])", l.Name()),
+			"Converts the current image to linear light, runs the given",
+		},
+		{
+			"",
+			"elements and converts the result back to sRGB.",
+		},
+	}
+}
+
+func (l linear) Encode(w pipeline.Writer) error {
+	return l.p.Encode(w)
+}
+
+func (l linear) Decode(r pipeline.Reader) (interface{}, error) {
+	p, err := (&pipeline.Pipeline{}).Decode(r)
+	if err != nil {
+		return l, err
+	}
+	l.p = p.(*pipeline.Pipeline)
+	return l, err
+}
+
+func (l linear) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(l)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(l.Name())
+	}
+
+	core.ToLinear(img)
+	img, err := l.p.Do(ctx, img)
+	if img != nil {
+		core.ToSRGB(img)
+	}
+
+	return img, err
+}
+
+type transfer struct {
+	linear bool
+}
+
+func (t transfer) Name() string {
+	if t.linear {
+		return "to-linear"
+	}
+	return "to-srgb"
+}
+
+func (transfer) Inline() bool                   { return true }
+func (transfer) Encode(w pipeline.Writer) error { return nil }
+
+func (t transfer) Decode(r pipeline.Reader) (interface{}, error) { return t, nil }
+
+func (t transfer) Help() [][2]string {
+	if t.linear {
+		return [][2]string{
+			{
+				fmt.Sprintf("%s()", t.Name()),
+				"Converts the current image from sRGB to linear light.",
+			},
+		}
+	}
+
+	return [][2]string{
+		{
+			fmt.Sprintf("%s()", t.Name()),
+			"Converts the current image from linear light to sRGB.",
+		},
+	}
+}
+
+func (t transfer) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(t)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(t.Name())
+	}
+
+	if t.linear {
+		core.ToLinear(img)
+		return img, nil
+	}
+	core.ToSRGB(img)
+
+	return img, nil
+}
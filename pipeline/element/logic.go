@@ -23,7 +23,8 @@ type or struct {
 	list []pipeline.Element
 }
 
-func (or or) Name() string { return "or" }
+func (or or) Name() string   { return "or" }
+func (or or) Stateful() bool { return pipeline.IsStateful(or.list...) }
 
 func (or or) Help() [][2]string {
 	return [][2]string{
@@ -86,7 +87,8 @@ func (e modeOnly) Name() string {
 	return v
 }
 
-func (e modeOnly) Inline() bool { return true }
+func (e modeOnly) Inline() bool   { return true }
+func (e modeOnly) Stateful() bool { return pipeline.IsStateful(e.p) }
 
 func (e modeOnly) Help() [][2]string {
 	h := [][2]string{
@@ -141,7 +143,8 @@ type teeElement struct {
 	p *pipeline.Pipeline
 }
 
-func (teeElement) Name() string { return "tee" }
+func (teeElement) Name() string       { return "tee" }
+func (tee teeElement) Stateful() bool { return pipeline.IsStateful(tee.p) }
 
 func (tee teeElement) Help() [][2]string {
 	return [][2]string{
@@ -17,6 +17,23 @@ func DenoiseLuma(radius int) pipeline.Element {
 	return denoise{chroma: false, radius: pipeline.PlainNumber(radius)}
 }
 
+func Grain(amount, size, roughness float64) pipeline.Element {
+	return grain{
+		amount:    pipeline.PlainNumber(amount),
+		size:      pipeline.PlainNumber(size),
+		roughness: pipeline.PlainNumber(roughness),
+	}
+}
+
+func GrainColor(amount, size, roughness, color float64) pipeline.Element {
+	return grain{
+		amount:    pipeline.PlainNumber(amount),
+		size:      pipeline.PlainNumber(size),
+		roughness: pipeline.PlainNumber(roughness),
+		color:     pipeline.PlainNumber(color),
+	}
+}
+
 type denoise struct {
 	chroma bool
 	radius pipeline.Value
@@ -62,7 +79,7 @@ func (dn denoise) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(dn.Name())
 	}
 
-	radius, err := dn.radius.Int(img)
+	radius, err := pipeline.ScaledInt(ctx, dn.radius, img)
 	if err != nil {
 		return img, err
 	}
@@ -84,3 +101,101 @@ func (dn denoise) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 
 	return img, nil
 }
+
+// grainSeed is fixed so renders (and thus cached results) are reproducible.
+const grainSeed = 0x70686f646f
+
+type grain struct {
+	amount    pipeline.Value
+	size      pipeline.Value
+	roughness pipeline.Value
+	color     pipeline.Value
+}
+
+func (grain) Name() string { return "grain" }
+func (grain) Inline() bool { return true }
+
+func (g grain) Encode(w pipeline.Writer) error {
+	w.Value(g.amount)
+	w.Value(g.size)
+	w.Value(g.roughness)
+	if g.color != nil {
+		w.Value(g.color)
+	}
+	return nil
+}
+
+func (g grain) Decode(r pipeline.Reader) (interface{}, error) {
+	g.amount = r.Value()
+	g.size = r.Value()
+	g.roughness = r.Value()
+	if r.Len() > 3 {
+		g.color = r.Value()
+	}
+	return g, nil
+}
+
+func (g grain) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<amount> <size> <roughness> [color])", g.Name()),
+			"Adds film grain that is strongest in the midtones. <amount> is its",
+		},
+		{
+			"",
+			"strength (e.g.: 0.05), <size> the grain size in pixels for an image",
+		},
+		{
+			"",
+			"with a short side of 2000px, it scales with the image resolution.",
+		},
+		{
+			"",
+			"<roughness> (0-1) mixes in finer grain and [color] (0-1, default 0)",
+		},
+		{
+			"",
+			"adds chromatic grain. The same image always gets the same grain,",
+		},
+		{
+			"",
+			"a negative <amount> inverts it.",
+		},
+	}
+}
+
+func (g grain) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(g)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(g.Name())
+	}
+
+	amount, err := g.amount.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	size, err := g.size.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	roughness, err := g.roughness.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	var color float64
+	if g.color != nil {
+		color, err = g.color.Float64(img)
+		if err != nil {
+			return img, err
+		}
+	}
+
+	if size <= 0 {
+		return img, errors.New("grain size must be larger than 0")
+	}
+
+	core.Grain(img, amount, size, roughness, color, grainSeed)
+
+	return img, nil
+}
@@ -9,7 +9,11 @@ import (
 func init() {
 	pipeline.RegisterNewContextHandler(func(ctx pipeline.Context) {
 		ctx.Set(StateStorageName, NewStateContainer())
-		ctx.Set(CacheStorageName, NewCacheContainer(8*1024*1024*1024))
+		c := NewCacheContainer(8 * 1024 * 1024 * 1024)
+		ctx.Set(CacheStorageName, c)
+		if ctx.Mode() == pipeline.ModeEdit {
+			ctx.Set(pipeline.CheckpointKey, NewCheckpointContainer(2*1024*1024*1024))
+		}
 
 		_, err := TTFFont(FontGoBold, gobold.TTF).Do(ctx, nil)
 		if err != nil {
@@ -26,6 +30,7 @@ func init() {
 
 	pipeline.Register(orient{})
 	pipeline.Register(rotate{})
+	pipeline.Register(straighten{})
 	pipeline.Register(hflip{})
 	pipeline.Register(vflip{})
 
@@ -52,6 +57,9 @@ func init() {
 	pipeline.Register(rgbMul{normalize: true})
 	pipeline.Register(rgbMul{normalize: false})
 	pipeline.Register(whiteBalanceSpot{})
+	pipeline.Register(whiteBalance{})
+	pipeline.Register(whiteBalanceFrom{})
+	pipeline.Register(whiteBalanceAuto{})
 
 	pipeline.Register(healSpot{})
 
@@ -69,6 +77,9 @@ func init() {
 	pipeline.Register(modeOnly{mode: pipeline.ModeConvert})
 	pipeline.Register(modeOnly{mode: pipeline.ModeScript})
 	pipeline.Register(modeOnly{mode: pipeline.ModeEdit})
+	pipeline.Register(linear{pipeline.New()})
+	pipeline.Register(transfer{linear: true})
+	pipeline.Register(transfer{linear: false})
 
 	pipeline.Register(calc{print: false})
 	pipeline.Register(calc{print: true})
@@ -76,9 +87,15 @@ func init() {
 
 	pipeline.Register(contrast{})
 	pipeline.Register(contrastY{})
+	pipeline.Register(clarity{})
+	pipeline.Register(shadowsHighlights{})
+	pipeline.Register(dehaze{})
+	pipeline.Register(vignette{})
 	pipeline.Register(brightness{})
 	pipeline.Register(gamma{})
+	pipeline.Register(exposure{})
 	pipeline.Register(saturation{})
+	pipeline.Register(hsl{})
 	pipeline.Register(black{})
 	pipeline.Register(eq{})
 
@@ -91,14 +108,24 @@ func init() {
 	pipeline.Register(draw{})
 	pipeline.Register(drawKey{})
 	pipeline.Register(drawMask{})
+	pipeline.Register(watermark{})
+	pipeline.Register(grid{})
 
 	pipeline.Register(HistogramElement{})
 
 	pipeline.Register(text{})
+	pipeline.Register(TextBoxElement{})
+	pipeline.Register(textMeasure{})
 	pipeline.Register(ttfFontFile{})
 
 	pipeline.Register(sharpen{})
+	pipeline.Register(unsharpMask{})
 
 	pipeline.Register(denoise{chroma: true})
 	pipeline.Register(denoise{chroma: false})
+	pipeline.Register(grain{})
+
+	pipeline.Register(blur{box: false})
+	pipeline.Register(blur{box: true})
+	pipeline.Register(blurBilateral{})
 }
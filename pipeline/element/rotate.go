@@ -8,10 +8,11 @@ import (
 	"github.com/frizinak/phodo/pipeline/element/core"
 )
 
-func CorrectOrientation() pipeline.Element { return orient{} }
-func Rotate(n int) pipeline.Element        { return rotate{pipeline.PlainNumber(n)} }
-func HFlip() pipeline.Element              { return hflip{} }
-func VFlip() pipeline.Element              { return vflip{} }
+func CorrectOrientation() pipeline.Element    { return orient{} }
+func Rotate(n int) pipeline.Element           { return rotate{pipeline.PlainNumber(n)} }
+func Straighten(deg float64) pipeline.Element { return straighten{pipeline.PlainNumber(deg)} }
+func HFlip() pipeline.Element                 { return hflip{} }
+func VFlip() pipeline.Element                 { return vflip{} }
 
 type orient struct{}
 
@@ -102,6 +103,54 @@ func (r rotate) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	return img, nil
 }
 
+type straighten struct{ deg pipeline.Value }
+
+func (straighten) Name() string { return "straighten" }
+func (straighten) Inline() bool { return true }
+
+func (s straighten) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<degrees>)", s.Name()),
+			"Rotates the image <degrees> clockwise and crops it to the largest",
+		},
+		{
+			"",
+			"rectangle with the same aspect ratio that has no empty corners.",
+		},
+	}
+}
+
+func (s straighten) Encode(w pipeline.Writer) error {
+	w.Value(s.deg)
+	return nil
+}
+
+func (s straighten) Decode(rdr pipeline.Reader) (interface{}, error) {
+	return straighten{
+		deg: rdr.Value(),
+	}, nil
+}
+
+func (s straighten) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(s)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(s.Name())
+	}
+
+	deg, err := s.deg.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	if deg < -45 || deg > 45 {
+		ctx.Warn(s, fmt.Sprintf("straightening by %g degrees, use rotate for quarter turns", deg))
+	}
+
+	return core.ImageStraighten(img, deg), nil
+}
+
 var rotations = map[int]int{
 	8: -1,
 	3: 2,
@@ -2,6 +2,7 @@ package element
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/frizinak/phodo/img48"
 	"github.com/frizinak/phodo/pipeline"
@@ -10,23 +11,86 @@ import (
 
 func Sharpen() pipeline.Element { return sharpen{} }
 
-type sharpen struct{}
+func SharpenOutput(preset SharpenPreset) pipeline.Element {
+	return sharpen{preset: pipeline.PlainString(preset)}
+}
+
+func UnsharpMask(radius, amount, threshold float64) pipeline.Element {
+	return unsharpMask{
+		radius:    pipeline.PlainNumber(radius),
+		amount:    pipeline.PlainNumber(amount),
+		threshold: pipeline.PlainNumber(threshold),
+	}
+}
+
+type SharpenPreset string
+
+const (
+	SharpenScreen       SharpenPreset = "screen"
+	SharpenScreenStrong SharpenPreset = "screen-strong"
+	SharpenPrint        SharpenPreset = "print"
+	SharpenPrintStrong  SharpenPreset = "print-strong"
+)
+
+type UnsharpParams struct {
+	Radius, Amount, Threshold float64
+}
+
+var sharpenPresets = map[SharpenPreset]UnsharpParams{
+	SharpenScreen:       {Radius: 0.5, Amount: 0.6, Threshold: 0.004},
+	SharpenScreenStrong: {Radius: 0.6, Amount: 1.2, Threshold: 0.004},
+	SharpenPrint:        {Radius: 1.0, Amount: 0.8, Threshold: 0.008},
+	SharpenPrintStrong:  {Radius: 1.5, Amount: 1.4, Threshold: 0.008},
+}
+
+func sharpenPresetList() []string {
+	list := make([]string, 0, len(sharpenPresets))
+	for k := range sharpenPresets {
+		list = append(list, string(k))
+	}
+	sort.Strings(list)
+	return list
+}
+
+type sharpen struct {
+	preset pipeline.Value
+}
 
 func (s sharpen) Name() string { return "sharpen" }
 func (s sharpen) Inline() bool { return true }
 
 func (s sharpen) Help() [][2]string {
-	return [][2]string{
+	h := [][2]string{
+		{
+			fmt.Sprintf("%s([preset])", s.Name()),
+			"Sharpens the image. If a [preset] is given, an unsharp mask with",
+		},
 		{
-			fmt.Sprintf("%s()", s.Name()),
-			"Sharpens the image.",
+			"",
+			"that preset's parameters is applied. [preset] can be one of:",
 		},
 	}
+
+	for _, k := range sharpenPresetList() {
+		h = append(h, [2]string{"", fmt.Sprintf(" - %s", k)})
+	}
+
+	return h
 }
 
-func (s sharpen) Encode(w pipeline.Writer) error { return nil }
+func (s sharpen) Encode(w pipeline.Writer) error {
+	if s.preset != nil {
+		w.Value(s.preset)
+	}
+	return nil
+}
 
-func (s sharpen) Decode(r pipeline.Reader) (interface{}, error) { return s, nil }
+func (s sharpen) Decode(r pipeline.Reader) (interface{}, error) {
+	if r.Len() > 0 {
+		s.preset = r.Value()
+	}
+	return s, nil
+}
 
 func (s sharpen) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	ctx.Mark(s)
@@ -35,7 +99,87 @@ func (s sharpen) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(s.Name())
 	}
 
-	core.SharpenLuminance(img)
+	if s.preset == nil {
+		core.SharpenLuminance(img)
+		return img, nil
+	}
+
+	v, err := s.preset.String(img)
+	if err != nil {
+		return img, err
+	}
+
+	p, ok := sharpenPresets[SharpenPreset(v)]
+	if !ok {
+		return img, fmt.Errorf("invalid sharpen preset '%s'", v)
+	}
+
+	core.UnsharpMaskLuminance(img, p.Radius, p.Amount, p.Threshold)
+
+	return img, nil
+}
+
+type unsharpMask struct {
+	radius    pipeline.Value
+	amount    pipeline.Value
+	threshold pipeline.Value
+}
+
+func (u unsharpMask) Name() string { return "unsharp-mask" }
+func (u unsharpMask) Inline() bool { return true }
+
+func (u unsharpMask) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<radius> <amount> <threshold>)", u.Name()),
+			"Sharpens the luminance by adding the difference with a gaussian",
+		},
+		{
+			"",
+			"blurred (sigma <radius>) copy multiplied by <amount>.",
+		},
+		{
+			"",
+			"Differences below <threshold> [0-1] are ignored.",
+		},
+	}
+}
+
+func (u unsharpMask) Encode(w pipeline.Writer) error {
+	w.Value(u.radius)
+	w.Value(u.amount)
+	w.Value(u.threshold)
+	return nil
+}
+
+func (u unsharpMask) Decode(r pipeline.Reader) (interface{}, error) {
+	u.radius = r.Value()
+	u.amount = r.Value()
+	u.threshold = r.Value()
+	return u, nil
+}
+
+func (u unsharpMask) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(u)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(u.Name())
+	}
+
+	radius, err := pipeline.ScaledFloat64(ctx, u.radius, img)
+	if err != nil {
+		return img, err
+	}
+	amount, err := u.amount.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	threshold, err := u.threshold.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	core.UnsharpMaskLuminance(img, radius, amount, threshold)
 
 	return img, nil
 }
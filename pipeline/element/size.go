@@ -2,6 +2,7 @@ package element
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/frizinak/phodo/img48"
 	"github.com/frizinak/phodo/pipeline"
@@ -13,9 +14,17 @@ const (
 	resizeNormal = "resize"
 	resizeClip   = "resize-clip"
 	resizeFit    = "resize-fit"
+
+	resizeSharpenPrefix = "sharpen-"
 )
 
 func Resize(w, h int, kernel Kernel, opts core.ResizeOptions) pipeline.Element {
+	return ResizeSharpen(w, h, kernel, opts, "")
+}
+
+// ResizeSharpen is Resize with an output sharpening preset that is applied
+// after resizing.
+func ResizeSharpen(w, h int, kernel Kernel, opts core.ResizeOptions, preset SharpenPreset) pipeline.Element {
 	rest := make([]pipeline.Value, 0)
 	if kernel != "" {
 		rest = append(rest, pipeline.PlainString(kernel))
@@ -23,6 +32,12 @@ func Resize(w, h int, kernel Kernel, opts core.ResizeOptions) pipeline.Element {
 	if opts&core.ResizeNoUpscale == 0 {
 		rest = append(rest, pipeline.PlainString("upscale"))
 	}
+	if opts&core.ResizeLinear != 0 {
+		rest = append(rest, pipeline.PlainString("linear"))
+	}
+	if preset != "" {
+		rest = append(rest, pipeline.PlainString(resizeSharpenPrefix+preset))
+	}
 
 	name := resizeNormal
 	if opts&core.ResizeMin != 0 {
@@ -85,12 +100,20 @@ func (resize) Inline() bool { return true }
 func (r resize) Help() [][2]string {
 	d := [][2]string{
 		{
-			fmt.Sprintf("%s(<width> <height> [kernel] [upscale])", r.Name()),
+			fmt.Sprintf("%s(<width> <height> [kernel] [upscale] [linear] [sharpen-<preset>])", r.Name()),
 			"Resize an image using an optional [kernel] and allow upscale if",
 		},
 		{
 			"",
-			"the string 'upscale' is given.",
+			"the string 'upscale' is given. If 'linear' is given, the image is",
+		},
+		{
+			"",
+			"resampled in linear light. If 'sharpen-<preset>' is given, the",
+		},
+		{
+			"",
+			"result is sharpened as sharpen(<preset>) would.",
 		},
 	}
 
@@ -164,6 +187,7 @@ func (r resize) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 
 	kernel := KernelBox
 	opts := core.ResizeNoUpscale
+	var sharp *UnsharpParams
 	n := r.Name()
 	if n == resizeClip {
 		opts |= core.ResizeMin
@@ -171,11 +195,11 @@ func (r resize) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		opts |= core.ResizeMax
 	}
 
-	w, err := r.w.Int(img)
+	w, err := pipeline.ScaledInt(ctx, r.w, img)
 	if err != nil {
 		return img, err
 	}
-	h, err := r.h.Int(img)
+	h, err := pipeline.ScaledInt(ctx, r.h, img)
 	if err != nil {
 		return img, err
 	}
@@ -193,10 +217,24 @@ func (r resize) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 			kernel = Kernel(str)
 		} else if str == "upscale" {
 			opts &= (^core.ResizeNoUpscale)
+		} else if str == "linear" {
+			opts |= core.ResizeLinear
+		} else if strings.HasPrefix(str, resizeSharpenPrefix) {
+			preset := SharpenPreset(str[len(resizeSharpenPrefix):])
+			p, ok := sharpenPresets[preset]
+			if !ok {
+				return img, fmt.Errorf("invalid sharpen preset '%s'", preset)
+			}
+			sharp = &p
 		}
 	}
 
-	return core.ImageResize(img, kernels[kernel], opts, w, h), nil
+	img = core.ImageResize(img, kernels[kernel], opts, w, h)
+	if sharp != nil {
+		core.UnsharpMaskLuminance(img, sharp.Radius, sharp.Amount, sharp.Threshold)
+	}
+
+	return img, nil
 }
 
 type crop struct {
@@ -249,24 +287,24 @@ func (c crop) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(c.Name())
 	}
 
-	x, err := c.x.Int(img)
+	x, err := pipeline.ScaledInt(ctx, c.x, img)
 	if err != nil {
 		return img, err
 	}
-	y, err := c.y.Int(img)
+	y, err := pipeline.ScaledInt(ctx, c.y, img)
 	if err != nil {
 		return img, err
 	}
 
 	var w, h int = img.Rect.Dx(), img.Rect.Dy()
 	if c.w != nil {
-		w, err = c.w.Int(img)
+		w, err = pipeline.ScaledInt(ctx, c.w, img)
 		if err != nil {
 			return img, err
 		}
 	}
 	if c.h != nil {
-		h, err = c.h.Int(img)
+		h, err = pipeline.ScaledInt(ctx, c.h, img)
 		if err != nil {
 			return img, err
 		}
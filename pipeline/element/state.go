@@ -87,7 +87,8 @@ func (s stateElement) Name() string {
 	return v
 }
 
-func (stateElement) Inline() bool { return true }
+func (stateElement) Inline() bool     { return true }
+func (s stateElement) Stateful() bool { return s.typ != stateRestore }
 
 func (s stateElement) Help() [][2]string {
 	switch s.typ {
@@ -48,6 +48,8 @@ type ttfFont struct {
 	d    []byte
 }
 
+func (ttfFont) Stateful() bool { return true }
+
 func (t ttfFont) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 	ctx.Mark(t)
 
@@ -115,6 +117,28 @@ func (t ttfFontFile) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error
 
 func FontKey(str Font) string { return fmt.Sprintf(":font:%s", str) }
 
+// contextFont returns the font loaded under the given name, falling back to
+// FontGo.
+func contextFont(ctx pipeline.Context, el pipeline.Element, fn string) (*sfnt.Font, error) {
+	_font := ctx.Get(FontKey(Font(fn)))
+	if _font == nil {
+		if fn != "" {
+			ctx.Warn(el, fmt.Sprintf("font not loaded: '%s'", fn))
+		}
+		_font = ctx.Get(FontKey(FontGo))
+	}
+
+	if _font == nil {
+		return nil, fmt.Errorf("font not loaded: '%s'", fn)
+	}
+	fnt, ok := _font.(*sfnt.Font)
+	if !ok {
+		return nil, fmt.Errorf("invalid font: '%s': %T", fn, _font)
+	}
+
+	return fnt, nil
+}
+
 type text struct {
 	x, y pipeline.Value
 	size pipeline.Value
@@ -167,15 +191,15 @@ func (t text) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, pipeline.NewErrNeedImageInput(t.Name())
 	}
 
-	x, err := t.x.Int(img)
+	x, err := pipeline.ScaledInt(ctx, t.x, img)
 	if err != nil {
 		return img, err
 	}
-	y, err := t.y.Int(img)
+	y, err := pipeline.ScaledInt(ctx, t.y, img)
 	if err != nil {
 		return img, err
 	}
-	size, err := t.size.Float64(img)
+	size, err := pipeline.ScaledFloat64(ctx, t.size, img)
 	if err != nil {
 		return img, err
 	}
@@ -196,20 +220,9 @@ func (t text) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		return img, fmt.Errorf("element of type '%T' is not a Color", _clr)
 	}
 
-	_font := ctx.Get(FontKey(Font(fn)))
-	if _font == nil {
-		if fn != "" {
-			ctx.Warn(t, fmt.Sprintf("font not loaded: '%s'", fn))
-		}
-		_font = ctx.Get(FontKey(FontGo))
-	}
-
-	if _font == nil {
-		return img, fmt.Errorf("font not loaded: '%s'", fn)
-	}
-	fnt, ok := _font.(*sfnt.Font)
-	if !ok {
-		return img, fmt.Errorf("invalid font: '%s': %T", fn, _font)
+	fnt, err := contextFont(ctx, t, fn)
+	if err != nil {
+		return img, err
 	}
 
 	r, g, b := clr.Color()
@@ -223,3 +236,372 @@ func (t text) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
 		fnt,
 	)
 }
+
+type TextAlign string
+
+const (
+	TextAlignStart  TextAlign = "start"
+	TextAlignEnd    TextAlign = "end"
+	TextAlignLeft   TextAlign = "left"
+	TextAlignCenter TextAlign = "center"
+	TextAlignRight  TextAlign = "right"
+)
+
+var textAligns = map[TextAlign]core.TextAlign{
+	TextAlignStart:  core.TextAlignStart,
+	TextAlignEnd:    core.TextAlignEnd,
+	TextAlignLeft:   core.TextAlignLeft,
+	TextAlignCenter: core.TextAlignCenter,
+	TextAlignRight:  core.TextAlignRight,
+}
+
+type TextVAlign string
+
+const (
+	TextVAlignTop    TextVAlign = "top"
+	TextVAlignMiddle TextVAlign = "middle"
+	TextVAlignBottom TextVAlign = "bottom"
+)
+
+var textVAligns = map[TextVAlign]core.TextVAlign{
+	TextVAlignTop:    core.TextVAlignTop,
+	TextVAlignMiddle: core.TextVAlignMiddle,
+	TextVAlignBottom: core.TextVAlignBottom,
+}
+
+func TextBox(x, y, w, h int, align TextAlign, valign TextVAlign, size float64, str string, clr pipeline.ComplexValue, f Font) TextBoxElement {
+	return TextBoxElement{
+		x:       pipeline.PlainNumber(x),
+		y:       pipeline.PlainNumber(y),
+		w:       pipeline.PlainNumber(w),
+		h:       pipeline.PlainNumber(h),
+		align:   pipeline.PlainString(align),
+		valign:  pipeline.PlainString(valign),
+		size:    pipeline.PlainNumber(size),
+		text:    pipeline.PlainString(str),
+		clr:     clr,
+		font:    pipeline.PlainString(f),
+		spacing: pipeline.PlainNumber(1),
+
+		outline:    pipeline.PlainNumber(0),
+		outlineClr: RGB16(0, 0, 0),
+		shadowX:    pipeline.PlainNumber(0),
+		shadowY:    pipeline.PlainNumber(0),
+		shadowClr:  RGB16(0, 0, 0),
+	}
+}
+
+type TextBoxElement struct {
+	x, y, w, h    pipeline.Value
+	align, valign pipeline.Value
+	size          pipeline.Value
+	text          pipeline.Value
+	clr           pipeline.ComplexValue
+	font          pipeline.Value
+	spacing       pipeline.Value
+
+	outline    pipeline.Value
+	outlineClr pipeline.ComplexValue
+
+	shadowX, shadowY pipeline.Value
+	shadowClr        pipeline.ComplexValue
+}
+
+func (t TextBoxElement) LineSpacing(spacing float64) TextBoxElement {
+	t.spacing = pipeline.PlainNumber(spacing)
+	return t
+}
+
+func (t TextBoxElement) Outline(width int, clr pipeline.ComplexValue) TextBoxElement {
+	t.outline, t.outlineClr = pipeline.PlainNumber(width), clr
+	return t
+}
+
+func (t TextBoxElement) Shadow(x, y int, clr pipeline.ComplexValue) TextBoxElement {
+	t.shadowX, t.shadowY = pipeline.PlainNumber(x), pipeline.PlainNumber(y)
+	t.shadowClr = clr
+	return t
+}
+
+func (TextBoxElement) Name() string { return "text-box" }
+func (TextBoxElement) Inline() bool { return true }
+
+func (t TextBoxElement) Encode(w pipeline.Writer) error {
+	w.Value(t.x)
+	w.Value(t.y)
+	w.Value(t.w)
+	w.Value(t.h)
+	w.Value(t.align)
+	w.Value(t.valign)
+	w.Value(t.size)
+	w.Value(t.text)
+	if err := w.ComplexValue(t.clr); err != nil {
+		return err
+	}
+	w.Value(t.font)
+	w.Value(t.spacing)
+	w.Value(t.outline)
+	if err := w.ComplexValue(t.outlineClr); err != nil {
+		return err
+	}
+	w.Value(t.shadowX)
+	w.Value(t.shadowY)
+	return w.ComplexValue(t.shadowClr)
+}
+
+func (t TextBoxElement) Decode(r pipeline.Reader) (interface{}, error) {
+	t.x = r.Value()
+	t.y = r.Value()
+	t.w = r.Value()
+	t.h = r.Value()
+	t.align = r.Value()
+	t.valign = r.Value()
+	t.size = r.Value()
+	t.text = r.Value()
+	t.clr = r.ComplexValueDefault(RGB16(0, 0, 0))
+	t.font = r.ValueDefault(pipeline.PlainString(FontGo))
+	t.spacing = r.ValueDefault(pipeline.PlainNumber(1))
+	t.outline = r.ValueDefault(pipeline.PlainNumber(0))
+	t.outlineClr = r.ComplexValueDefault(RGB16(0, 0, 0))
+	t.shadowX = r.ValueDefault(pipeline.PlainNumber(0))
+	t.shadowY = r.ValueDefault(pipeline.PlainNumber(0))
+	t.shadowClr = r.ComplexValueDefault(RGB16(0, 0, 0))
+
+	return t, nil
+}
+
+func (t TextBoxElement) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<x> <y> <w> <h> <align> <valign> <size> <text>", t.Name()),
+			"Prints text inside the given box, wrapping lines at <w>.",
+		},
+		{
+			"  [color] [font] [line-spacing]",
+			"A <w> or <h> of 0 grows the box to fit the text, <x> <y> is then",
+		},
+		{
+			"  [outline-width] [outline-color]",
+			"the anchor for the alignment.",
+		},
+		{
+			"  [shadow-x] [shadow-y] [shadow-color])",
+			"<align> can be one of start, end, left, center or right, where start",
+		},
+		{
+			"",
+			"and end depend on the direction (left-to-right or right-to-left)",
+		},
+		{
+			"",
+			"of each line.",
+		},
+		{
+			"",
+			"<valign> can be one of top, middle or bottom.",
+		},
+		{
+			"",
+			"A drop shadow is only drawn if its offset is not 0.",
+		},
+	}
+}
+
+func (t TextBoxElement) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(t)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(t.Name())
+	}
+
+	var x, y, w, h, outline, sx, sy int
+	for _, v := range []struct {
+		v pipeline.Value
+		n *int
+	}{
+		{t.x, &x}, {t.y, &y}, {t.w, &w}, {t.h, &h},
+		{t.outline, &outline}, {t.shadowX, &sx}, {t.shadowY, &sy},
+	} {
+		var err error
+		if *v.n, err = pipeline.ScaledInt(ctx, v.v, img); err != nil {
+			return img, err
+		}
+	}
+
+	size, err := pipeline.ScaledFloat64(ctx, t.size, img)
+	if err != nil {
+		return img, err
+	}
+	spacing, err := t.spacing.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	txt, err := t.text.String(img)
+	if err != nil {
+		return img, err
+	}
+	fn, err := t.font.String(img)
+	if err != nil {
+		return img, err
+	}
+
+	_align, err := t.align.String(img)
+	if err != nil {
+		return img, err
+	}
+	align, ok := textAligns[TextAlign(_align)]
+	if !ok {
+		return img, fmt.Errorf("invalid text alignment '%s'", _align)
+	}
+	_valign, err := t.valign.String(img)
+	if err != nil {
+		return img, err
+	}
+	valign, ok := textVAligns[TextVAlign(_valign)]
+	if !ok {
+		return img, fmt.Errorf("invalid vertical text alignment '%s'", _valign)
+	}
+
+	clrs := make([]core.Color, 3)
+	for i, v := range []pipeline.ComplexValue{t.clr, t.outlineClr, t.shadowClr} {
+		_clr, err := v.Value(img)
+		if err != nil {
+			return img, err
+		}
+		clr, ok := _clr.(core.Color)
+		if !ok {
+			return img, fmt.Errorf("element of type '%T' is not a Color", _clr)
+		}
+		clrs[i] = clr
+	}
+
+	fnt, err := contextFont(ctx, t, fn)
+	if err != nil {
+		return img, err
+	}
+
+	return img, core.TextBox(img, core.TextBoxOptions{
+		Rect:        image.Rect(x, y, x+w, y+h),
+		Align:       align,
+		VAlign:      valign,
+		Size:        size,
+		LineSpacing: spacing,
+		Text:        txt,
+		Font:        fnt,
+
+		Color: clrs[0],
+
+		OutlineWidth: outline,
+		OutlineColor: clrs[1],
+
+		ShadowOffset: image.Pt(sx, sy),
+		ShadowColor:  clrs[2],
+	})
+}
+
+type textMeasure struct {
+	variable pipeline.Value
+	w        pipeline.Value
+	size     pipeline.Value
+	text     pipeline.Value
+	font     pipeline.Value
+	spacing  pipeline.Value
+	anko     func(string) pipeline.Value
+}
+
+func (textMeasure) Name() string   { return "text-measure" }
+func (textMeasure) Stateful() bool { return true }
+func (textMeasure) Inline() bool   { return true }
+
+func (t textMeasure) Encode(w pipeline.Writer) error {
+	w.Value(t.variable)
+	w.Value(t.w)
+	w.Value(t.size)
+	w.Value(t.text)
+	w.Value(t.font)
+	w.Value(t.spacing)
+	return nil
+}
+
+func (t textMeasure) Decode(r pipeline.Reader) (interface{}, error) {
+	t.anko = r.Anko
+	t.variable = r.Value()
+	t.w = r.Value()
+	t.size = r.Value()
+	t.text = r.Value()
+	t.font = r.ValueDefault(pipeline.PlainString(FontGo))
+	t.spacing = r.ValueDefault(pipeline.PlainNumber(1))
+
+	return t, nil
+}
+
+func (t textMeasure) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<var> <w> <size> <text> [font] [line-spacing])", t.Name()),
+			"Measures text as it would be laid out by text-box with the given",
+		},
+		{
+			"",
+			"<w> and assigns its size to the anko variables <var>_width and",
+		},
+		{
+			"",
+			"<var>_height.",
+		},
+		{
+			"",
+			"e.g.: text-measure(caption 0 24 \"Hello\")",
+		},
+		{
+			"",
+			"      text-box(`width - caption_width - 10` 10 0 0 left top 24 \"Hello\")",
+		},
+	}
+}
+
+func (t textMeasure) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(t)
+
+	// The measured size is in full resolution units like any other
+	// variable, see pipeline.FullSize.
+	full := pipeline.FullSize(ctx, img)
+
+	variable, err := t.variable.String(full)
+	if err != nil {
+		return img, err
+	}
+	w, err := t.w.Int(full)
+	if err != nil {
+		return img, err
+	}
+	size, err := t.size.Float64(full)
+	if err != nil {
+		return img, err
+	}
+	spacing, err := t.spacing.Float64(full)
+	if err != nil {
+		return img, err
+	}
+	txt, err := t.text.String(full)
+	if err != nil {
+		return img, err
+	}
+	fn, err := t.font.String(full)
+	if err != nil {
+		return img, err
+	}
+
+	fnt, err := contextFont(ctx, t, fn)
+	if err != nil {
+		return img, err
+	}
+
+	tw, th, err := core.TextMeasure(fnt, size, spacing, txt, w)
+	if err != nil {
+		return img, err
+	}
+
+	_, err = t.anko(fmt.Sprintf("%s_width = %d; %s_height = %d", variable, tw, variable, th)).Value(full)
+	return img, err
+}
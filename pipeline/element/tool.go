@@ -0,0 +1,74 @@
+package element
+
+import (
+	"image"
+	"math"
+
+	"github.com/frizinak/phodo/pipeline"
+)
+
+// The Tool* functions create the element for a selection made on the result
+// of a pipeline whose last element is last (which may be nil). If last is of
+// the same kind and can be combined with the selection, the combined element
+// is returned with replace set to true and should take its place. Otherwise
+// the element should be appended.
+
+// ToolCrop crops to r. A preceding crop with plain, positive arguments is
+// narrowed down.
+func ToolCrop(last pipeline.Element, r image.Rectangle) (el pipeline.Element, replace bool) {
+	r = r.Canon()
+	el = Crop(r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+
+	c, ok := last.(crop)
+	if !ok {
+		return el, false
+	}
+
+	x, okx := plainInt(c.x)
+	y, oky := plainInt(c.y)
+	if !okx || !oky {
+		return el, false
+	}
+	for _, v := range []pipeline.Value{c.w, c.h} {
+		if v == nil {
+			continue
+		}
+		if n, ok := plainInt(v); !ok || n < 0 {
+			return el, false
+		}
+	}
+
+	return Crop(x+r.Min.X, y+r.Min.Y, r.Dx(), r.Dy()), true
+}
+
+// ToolWhiteBalanceSpot white balances on the given spot. A preceding
+// white-balance-spot is replaced.
+func ToolWhiteBalanceSpot(last pipeline.Element, x, y, r int) (el pipeline.Element, replace bool) {
+	_, replace = last.(whiteBalanceSpot)
+	return WhiteBalanceSpot(x, y, r), replace
+}
+
+// ToolStraighten levels the line from x1,y1 to x2,y2, lines that are closer
+// to vertical than to horizontal are made vertical. A preceding straighten
+// with a plain argument is adjusted.
+func ToolStraighten(last pipeline.Element, x1, y1, x2, y2 int) (el pipeline.Element, replace bool) {
+	deg := math.Atan2(float64(y2-y1), float64(x2-x1)) * 180 / math.Pi
+	deg -= 90 * math.Round(deg/90)
+	round := func(deg float64) float64 {
+		// Adding 0 turns -0 into 0.
+		return math.Round(deg*100)/100 + 0
+	}
+
+	if s, ok := last.(straighten); ok {
+		if n, ok := s.deg.(pipeline.PlainNumber); ok {
+			return Straighten(round(float64(n) - deg)), true
+		}
+	}
+
+	return Straighten(round(-deg)), false
+}
+
+func plainInt(v pipeline.Value) (int, bool) {
+	n, ok := v.(pipeline.PlainNumber)
+	return int(n), ok && float64(n) == math.Trunc(float64(n))
+}
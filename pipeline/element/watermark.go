@@ -0,0 +1,196 @@
+package element
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/frizinak/phodo/pipeline"
+	"github.com/frizinak/phodo/pipeline/element/core"
+)
+
+type Anchor string
+
+const (
+	AnchorTopLeft     Anchor = "top-left"
+	AnchorTop         Anchor = "top"
+	AnchorTopRight    Anchor = "top-right"
+	AnchorLeft        Anchor = "left"
+	AnchorCenter      Anchor = "center"
+	AnchorRight       Anchor = "right"
+	AnchorBottomLeft  Anchor = "bottom-left"
+	AnchorBottom      Anchor = "bottom"
+	AnchorBottomRight Anchor = "bottom-right"
+)
+
+// anchors maps each Anchor to its horizontal and vertical position where
+// 0 is left/top, 1 is center and 2 is right/bottom.
+var anchors = map[Anchor][2]int{
+	AnchorTopLeft:     {0, 0},
+	AnchorTop:         {1, 0},
+	AnchorTopRight:    {2, 0},
+	AnchorLeft:        {0, 1},
+	AnchorCenter:      {1, 1},
+	AnchorRight:       {2, 1},
+	AnchorBottomLeft:  {0, 2},
+	AnchorBottom:      {1, 2},
+	AnchorBottomRight: {2, 2},
+}
+
+// Position returns the offset at which a rect of size src should be placed
+// inside dst, keeping margin pixels from the anchored edges.
+func (a Anchor) Position(dst, src image.Point, margin int) (image.Point, error) {
+	pos, ok := anchors[a]
+	if !ok {
+		return image.Point{}, fmt.Errorf("invalid anchor '%s'", a)
+	}
+
+	c := func(pos, d, s int) int {
+		switch pos {
+		case 0:
+			return margin
+		case 2:
+			return d - s - margin
+		}
+		return (d - s) / 2
+	}
+
+	return image.Point{c(pos[0], dst.X, src.X), c(pos[1], dst.Y, src.Y)}, nil
+}
+
+func anchorList() []string {
+	list := make([]string, 0, len(anchors))
+	for k := range anchors {
+		list = append(list, string(k))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func Watermark(anchor Anchor, margin int, scale, opacity float64, src pipeline.Element) pipeline.Element {
+	return watermark{
+		anchor: pipeline.PlainString(anchor),
+		margin: pipeline.PlainNumber(margin),
+		scale:  pipeline.PlainNumber(scale),
+		blend:  pipeline.PlainNumber(opacity),
+		el:     src,
+	}
+}
+
+type watermark struct {
+	anchor pipeline.Value
+	margin pipeline.Value
+	scale  pipeline.Value
+	blend  pipeline.Value
+	el     pipeline.Element
+}
+
+func (watermark) Name() string     { return "watermark" }
+func (w watermark) Stateful() bool { return pipeline.IsStateful(w.el) }
+func (watermark) Inline() bool     { return false }
+
+func (w watermark) Help() [][2]string {
+	v := [][2]string{
+		{
+			fmt.Sprintf("%s(<anchor> <margin> <scale> <blend mode> <src-element>)", w.Name()),
+			"Draws the src-element onto the current image at the given anchor,",
+		},
+		{
+			"",
+			"<margin> pixels from the nearest edges. The src-element is resized",
+		},
+		{
+			"",
+			"to <scale> times the width of the current image, 0 disables resizing.",
+		},
+		{
+			"",
+			"<blend mode> can be a number (opacity) or a blend mode as in draw.",
+		},
+		{
+			"",
+			"<anchor> can be one of:",
+		},
+	}
+
+	for _, k := range anchorList() {
+		v = append(v, [2]string{"", fmt.Sprintf(" - %s", k)})
+	}
+
+	return v
+}
+
+func (w watermark) Encode(wr pipeline.Writer) error {
+	wr.Value(w.anchor)
+	wr.Value(w.margin)
+	wr.Value(w.scale)
+	wr.Value(w.blend)
+	return wr.Element(w.el)
+}
+
+func (w watermark) Decode(r pipeline.Reader) (interface{}, error) {
+	w.anchor = r.Value()
+	w.margin = r.Value()
+	w.scale = r.Value()
+	w.blend = r.Value()
+	w.el = r.Element()
+	return w, nil
+}
+
+func (w watermark) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(w)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(w.Name())
+	}
+
+	anchor, err := w.anchor.String(img)
+	if err != nil {
+		return img, err
+	}
+	margin, err := pipeline.ScaledInt(ctx, w.margin, img)
+	if err != nil {
+		return img, err
+	}
+	scale, err := w.scale.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	mode, err := w.blend.String(img)
+	if err != nil {
+		return img, err
+	}
+	blender, err := parseBlendMode(mode)
+	if err != nil {
+		return img, err
+	}
+
+	src, err := w.el.Do(ctx, img)
+	if err != nil {
+		return img, err
+	}
+	if src == nil {
+		return img, pipeline.NewErrNeedImageInput(w.Name())
+	}
+
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if scale > 0 && sw > 0 {
+		nw := int(scale*float64(img.Rect.Dx()) + 0.5)
+		nh := int(float64(nw)*float64(sh)/float64(sw) + 0.5)
+		src = core.ImageResize(src, kernels[KernelBox], 0, nw, nh)
+	}
+
+	pt, err := Anchor(anchor).Position(
+		img.Rect.Size(),
+		src.Rect.Size(),
+		margin,
+	)
+	if err != nil {
+		return img, err
+	}
+
+	core.Draw(src, img, pt, blender)
+
+	return img, nil
+}
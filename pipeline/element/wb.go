@@ -2,6 +2,7 @@ package element
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/frizinak/phodo/img48"
 	"github.com/frizinak/phodo/pipeline"
@@ -33,6 +34,36 @@ func WhiteBalanceSpot(x, y, r int) pipeline.Element {
 	}
 }
 
+func WhiteBalance(kelvin, tint float64) pipeline.Element {
+	return whiteBalance{
+		kelvin: pipeline.PlainNumber(kelvin),
+		tint:   pipeline.PlainNumber(tint),
+	}
+}
+
+func WhiteBalanceFrom(from, to float64) pipeline.Element {
+	return whiteBalanceFrom{
+		from: pipeline.PlainNumber(from),
+		to:   pipeline.PlainNumber(to),
+	}
+}
+
+func WhiteBalanceAuto(method WhiteBalanceMethod) pipeline.Element {
+	return whiteBalanceAuto{method: pipeline.PlainString(method)}
+}
+
+type WhiteBalanceMethod string
+
+const (
+	WhiteBalanceGreyWorld  WhiteBalanceMethod = "grey-world"
+	WhiteBalanceWhitePatch WhiteBalanceMethod = "white-patch"
+)
+
+var whiteBalanceMethods = map[WhiteBalanceMethod]core.WhiteBalanceMethod{
+	WhiteBalanceGreyWorld:  core.WhiteBalanceGreyWorld,
+	WhiteBalanceWhitePatch: core.WhiteBalanceWhitePatch,
+}
+
 type rgbAdd struct{ r, g, b pipeline.Value }
 
 func (rgbAdd) Name() string { return "rgb-add" }
@@ -208,15 +239,15 @@ func (wb whiteBalanceSpot) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img,
 		return img, pipeline.NewErrNeedImageInput(wb.Name())
 	}
 
-	x, err := wb.x.Int(img)
+	x, err := pipeline.ScaledInt(ctx, wb.x, img)
 	if err != nil {
 		return img, err
 	}
-	y, err := wb.y.Int(img)
+	y, err := pipeline.ScaledInt(ctx, wb.y, img)
 	if err != nil {
 		return img, err
 	}
-	radius, err := wb.r.Int(img)
+	radius, err := pipeline.ScaledInt(ctx, wb.r, img)
 	if err != nil {
 		return img, err
 	}
@@ -227,3 +258,182 @@ func (wb whiteBalanceSpot) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img,
 
 	return img, nil
 }
+
+type whiteBalance struct {
+	kelvin pipeline.Value
+	tint   pipeline.Value
+}
+
+func (whiteBalance) Name() string { return "white-balance" }
+func (whiteBalance) Inline() bool { return true }
+
+func (wb whiteBalance) Encode(w pipeline.Writer) error {
+	w.Value(wb.kelvin)
+	w.Value(wb.tint)
+	return nil
+}
+
+func (wb whiteBalance) Decode(r pipeline.Reader) (interface{}, error) {
+	wb.kelvin = r.Value()
+	wb.tint = r.Value()
+	return wb, nil
+}
+
+func (wb whiteBalance) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<kelvin> <tint>)", wb.Name()),
+			"Neutralizes the color cast of a light source with the given",
+		},
+		{
+			"",
+			"color temperature (e.g.: 3200 for tungsten, lower values result in",
+		},
+		{
+			"",
+			"a cooler image) using a Bradford chromatic adaptation in linear",
+		},
+		{
+			"",
+			"light. Positive <tint> values result in a more magenta image.",
+		},
+	}
+}
+
+func (wb whiteBalance) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(wb)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(wb.Name())
+	}
+
+	kelvin, err := wb.kelvin.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	tint, err := wb.tint.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	core.WhiteBalanceKelvin(img, kelvin, tint)
+
+	return img, nil
+}
+
+type whiteBalanceFrom struct {
+	from, to pipeline.Value
+}
+
+func (whiteBalanceFrom) Name() string { return "white-balance-from" }
+func (whiteBalanceFrom) Inline() bool { return true }
+
+func (wb whiteBalanceFrom) Encode(w pipeline.Writer) error {
+	w.Value(wb.from)
+	w.Value(wb.to)
+	return nil
+}
+
+func (wb whiteBalanceFrom) Decode(r pipeline.Reader) (interface{}, error) {
+	wb.from = r.Value()
+	wb.to = r.Value()
+	return wb, nil
+}
+
+func (wb whiteBalanceFrom) Help() [][2]string {
+	return [][2]string{
+		{
+			fmt.Sprintf("%s(<from-kelvin> <to-kelvin>)", wb.Name()),
+			"Changes the white balance of an image that was balanced for a",
+		},
+		{
+			"",
+			"light source of <from-kelvin> as if it were balanced for one of",
+		},
+		{
+			"",
+			"<to-kelvin> (e.g.: 5000 6500 results in a warmer image).",
+		},
+	}
+}
+
+func (wb whiteBalanceFrom) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(wb)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(wb.Name())
+	}
+
+	from, err := wb.from.Float64(img)
+	if err != nil {
+		return img, err
+	}
+	to, err := wb.to.Float64(img)
+	if err != nil {
+		return img, err
+	}
+
+	core.WhiteBalanceKelvinFrom(img, from, to)
+
+	return img, nil
+}
+
+type whiteBalanceAuto struct {
+	method pipeline.Value
+}
+
+func (whiteBalanceAuto) Name() string { return "white-balance-auto" }
+func (whiteBalanceAuto) Inline() bool { return true }
+
+func (wb whiteBalanceAuto) Encode(w pipeline.Writer) error {
+	w.Value(wb.method)
+	return nil
+}
+
+func (wb whiteBalanceAuto) Decode(r pipeline.Reader) (interface{}, error) {
+	wb.method = r.Value()
+	return wb, nil
+}
+
+func (wb whiteBalanceAuto) Help() [][2]string {
+	list := make([]string, 0, len(whiteBalanceMethods))
+	for k := range whiteBalanceMethods {
+		list = append(list, string(k))
+	}
+	sort.Strings(list)
+
+	v := [][2]string{
+		{
+			fmt.Sprintf("%s(<method>)", wb.Name()),
+			"Adjusts the whitebalance automatically. <method> can be one of:",
+		},
+	}
+	for _, k := range list {
+		v = append(v, [2]string{"", fmt.Sprintf(" - %s", k)})
+	}
+	return append(v,
+		[2]string{"", "grey-world assumes the average color of the image is grey,"},
+		[2]string{"", "white-patch that its brightest unclipped pixels are white."},
+	)
+}
+
+func (wb whiteBalanceAuto) Do(ctx pipeline.Context, img *img48.Img) (*img48.Img, error) {
+	ctx.Mark(wb)
+
+	if img == nil {
+		return img, pipeline.NewErrNeedImageInput(wb.Name())
+	}
+
+	_method, err := wb.method.String(img)
+	if err != nil {
+		return img, err
+	}
+	method, ok := whiteBalanceMethods[WhiteBalanceMethod(_method)]
+	if !ok {
+		return img, fmt.Errorf("invalid white balance method '%s'", _method)
+	}
+
+	core.WhiteBalanceAuto(img, method)
+
+	return img, nil
+}
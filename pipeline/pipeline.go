@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/frizinak/phodo/img48"
@@ -20,6 +22,16 @@ func NewErrNeedImageInput(whoyou string) error {
 	return ErrNeedImageInput{whoyou}
 }
 
+// LineError is an error returned by the element on the given line of the
+// script it was decoded from.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e LineError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Err) }
+func (e LineError) Unwrap() error { return e.Err }
+
 const NamedPrefix = '.'
 const anonPipeline = "pipeline"
 
@@ -42,6 +54,8 @@ type Element interface {
 type Pipeline struct {
 	name   string
 	line   []Element
+	hashes []Hash
+	lines  []int
 	result struct {
 		img *img48.Img
 		err error
@@ -64,6 +78,16 @@ func (p *Pipeline) add(e Element) {
 	p.line = append(p.line, e)
 }
 
+// Elements returns the elements of the pipeline.
+func (p *Pipeline) Elements() []Element {
+	l := make([]Element, len(p.line))
+	copy(l, p.line)
+	return l
+}
+
+// Stateful implements Stateful.
+func (p *Pipeline) Stateful() bool { return IsStateful(p.line...) }
+
 func (p *Pipeline) SetName(name string) {
 	if name == "" || name == anonPipeline {
 		p.name = ""
@@ -110,15 +134,29 @@ func (p *Pipeline) Do(ctx Context, img *img48.Img) (*img48.Img, error) {
 		ctx.Mark(p, p.Name())
 	}
 
-	for _, e := range p.line {
+	start := 0
+	store, sums := p.checkpoints(ctx, p.result.img)
+	if store != nil {
+		ctx.Set(checkpointingKey, true)
+		defer ctx.Set(checkpointingKey, false)
+		if img, i, ok := store.Checkpoint(p.name, sums[:p.resumable()]); ok {
+			p.result.img, start = img, i+1
+		}
+	}
+
+	for i := start; i < len(p.line); i++ {
 		if err := ctx.Err(); err != nil {
 			p.result.err = err
 			break
 		}
-		p.result.img, p.result.err = e.Do(ctx, p.result.img)
+		p.result.img, p.result.err = p.line[i].Do(ctx, p.result.img)
 		if p.result.err != nil {
+			p.result.err = p.lineError(i, p.result.err)
 			break
 		}
+		if store != nil && p.result.img != nil {
+			store.SetCheckpoint(p.name, i, sums[i], p.result.img)
+		}
 	}
 
 	ctx.Mark(nil)
@@ -126,6 +164,22 @@ func (p *Pipeline) Do(ctx Context, img *img48.Img) (*img48.Img, error) {
 	return p.result.img, p.result.err
 }
 
+// lineError adds the line of the i-th element to err unless it already has
+// one or the pipeline was canceled.
+func (p *Pipeline) lineError(i int, err error) error {
+	var lerr LineError
+	switch {
+	case i >= len(p.lines) || p.lines[i] == 0:
+		return err
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.As(err, &lerr):
+		return err
+	}
+
+	return LineError{Line: p.lines[i], Err: err}
+}
+
 func (p *Pipeline) Encode(w Writer) error {
 	for _, e := range p.line {
 		if err := w.Element(e); err != nil {
@@ -149,6 +203,14 @@ func (p *Pipeline) Decode(r Reader) (interface{}, error) {
 
 	pipe := mk(els)
 	pipe.SetName(name)
-	return pipe, nil
+	if e, ok := r.(*entry); ok {
+		pipe.hashes = make([]Hash, l)
+		pipe.lines = make([]int, l)
+		for i, v := range e.values {
+			pipe.hashes[i] = v.sum
+			pipe.lines[i] = v.line
+		}
+	}
 
+	return pipe, nil
 }
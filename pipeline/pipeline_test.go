@@ -1,7 +1,16 @@
 package pipeline
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"strings"
 	"testing"
+
+	"github.com/frizinak/phodo/img48"
+	"github.com/mattn/anko/env"
 )
 
 func TestPlainNumber(t *testing.T) {
@@ -36,3 +45,206 @@ func TestPlainNumber(t *testing.T) {
 		}
 	}
 }
+
+func TestSource(t *testing.T) {
+	script := `// comment
+.main(
+    load-file("a b.jpg")
+    crop(0 0 ${w} 100)
+    Ü(\"x\" ` + "`1 + 2`" + `)
+)
+
+.other(hflip())
+`
+
+	d := NewDecoder(strings.NewReader(script), map[string]string{"w": "50"}, nil)
+	if err := d.decode(env.NewEnv(), d.vars, &[]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	span, args, ok := d.Source(".main")
+	if !ok {
+		t.Fatal("no .main")
+	}
+	if got := script[span.Start:span.End]; !strings.HasPrefix(got, ".main(") || !strings.HasSuffix(got, ")") {
+		t.Fatalf("invalid span for .main: '%s'", got)
+	}
+
+	exp := []string{
+		`load-file("a b.jpg")`,
+		"crop(0 0 ${w} 100)",
+		"Ü(\\\"x\\\" `1 + 2`)",
+	}
+	if len(args) != len(exp) {
+		t.Fatalf("expected %d args, got %d", len(exp), len(args))
+	}
+	for i, e := range exp {
+		if got := script[args[i].Start:args[i].End]; got != e {
+			t.Fatalf("expected '%s' got '%s'", e, got)
+		}
+	}
+
+	span, _, ok = d.Source(".other")
+	if !ok || script[span.Start:span.End] != ".other(hflip())" {
+		t.Fatalf("invalid span for .other: %+v", span)
+	}
+}
+
+func TestScaled(t *testing.T) {
+	ctx := NewContext(0, io.Discard, ModeConvert, context.Background())
+	img := img48.New(image.Rect(0, 0, 100, 50), nil)
+	if n, _ := ScaledInt(ctx, PlainNumber(30), img); n != 30 {
+		t.Fatalf("unscaled: expected 30, got %d", n)
+	}
+
+	SetScale(ctx, 0.25)
+	if n, _ := ScaledInt(ctx, PlainNumber(30), img); n != 8 {
+		t.Fatalf("scaled int: expected 8, got %d", n)
+	}
+	if n, _ := ScaledFloat64(ctx, PlainNumber(30), img); n != 7.5 {
+		t.Fatalf("scaled float: expected 7.5, got %f", n)
+	}
+	if s := FullSize(ctx, img).Rect; s.Dx() != 400 || s.Dy() != 200 {
+		t.Fatalf("full size: expected 400x200, got %dx%d", s.Dx(), s.Dy())
+	}
+
+}
+
+type testCount struct {
+	n    Value
+	runs map[int]int
+}
+
+func (t testCount) Name() string      { return "test-count" }
+func (t testCount) Help() [][2]string { return nil }
+func (t testCount) Decode(r Reader) (interface{}, error) {
+	t.n = r.Value()
+	return t, nil
+}
+
+func (t testCount) Do(ctx Context, img *img48.Img) (*img48.Img, error) {
+	n, err := t.n.Int(img)
+	t.runs[n]++
+	img.Pix[0] += uint16(n)
+	return img, err
+}
+
+type testCheckpoint struct {
+	sum []byte
+	img *img48.Img
+}
+
+type testCheckpoints map[string][]*testCheckpoint
+
+func (c testCheckpoints) Checkpoint(name string, sums [][]byte) (*img48.Img, int, bool) {
+	chain := c[name]
+	for i := len(sums) - 1; i >= 0; i-- {
+		if i < len(chain) && chain[i] != nil && bytes.Equal(chain[i].sum, sums[i]) {
+			cp := *chain[i].img
+			cp.Pix = append([]uint16{}, cp.Pix...)
+			return &cp, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (c testCheckpoints) SetCheckpoint(name string, i int, sum []byte, img *img48.Img) {
+	chain := c[name]
+	if i < len(chain) {
+		chain = chain[:i]
+	}
+	for len(chain) < i {
+		chain = append(chain, nil)
+	}
+	cp := *img
+	cp.Pix = append([]uint16{}, img.Pix...)
+	c[name] = append(chain, &testCheckpoint{sum, &cp})
+}
+
+func TestCheckpoints(t *testing.T) {
+	runs := make(map[int]int)
+	Register(testCount{runs: runs})
+
+	ctx := NewContext(0, io.Discard, ModeEdit, context.Background())
+	ctx.Set(CheckpointKey, testCheckpoints{})
+
+	run := func(script string, input uint16) uint16 {
+		res, err := NewDecoder(strings.NewReader(script), nil, nil).Decode(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		el, _ := res.Get(".main")
+		img := img48.New(image.Rect(0, 0, 1, 1), nil)
+		img.Pix[0] = input
+		out, err := el.Element.Do(ctx, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.Pix[0]
+	}
+
+	expect := func(v, exp uint16, runs1, runs2, runs3 int) {
+		t.Helper()
+		if v != exp {
+			t.Fatalf("expected result %d, got %d", exp, v)
+		}
+		if runs[1] != runs1 || runs[2] != runs2 || runs[3] != runs3 {
+			t.Fatalf("expected runs %d %d %d, got %v", runs1, runs2, runs3, runs)
+		}
+	}
+
+	expect(run(".main(test-count(1) test-count(2))", 0), 3, 1, 1, 0)
+	expect(run(".main(test-count(1) test-count(3))", 0), 4, 1, 1, 1)
+	// Only the latest run is kept.
+	expect(run(".main(test-count(1) test-count(2))", 0), 3, 1, 2, 1)
+	expect(run(".main(test-count(1) test-count(2))", 0), 3, 1, 2, 1)
+	expect(run(".main(test-count(1) test-count(2))", 10), 13, 2, 3, 1)
+	expect(run(".a(test-count(1)) .main(.a test-count(2))", 0), 3, 3, 4, 1)
+	expect(run(".a(test-count(3)) .main(.a test-count(2))", 0), 5, 3, 5, 2)
+
+	// Elements that change the context are never skipped.
+	var states int
+	Register(testState{runs: &states})
+	expect(run(".main(test-count(1) test-state() test-count(2))", 0), 3, 4, 6, 2)
+	expect(run(".main(test-count(1) test-state() test-count(3))", 0), 4, 4, 6, 3)
+	if states != 2 {
+		t.Fatalf("expected the stateful element to run twice, got %d", states)
+	}
+}
+
+type testState struct{ runs *int }
+
+func (testState) Name() string                           { return "test-state" }
+func (testState) Help() [][2]string                      { return nil }
+func (testState) Stateful() bool                         { return true }
+func (t testState) Decode(r Reader) (interface{}, error) { return t, nil }
+func (t testState) Do(ctx Context, img *img48.Img) (*img48.Img, error) {
+	*t.runs++
+	return img, nil
+}
+
+type testFail struct{}
+
+func (testFail) Name() string                           { return "test-fail" }
+func (testFail) Help() [][2]string                      { return nil }
+func (t testFail) Decode(r Reader) (interface{}, error) { return t, nil }
+func (testFail) Do(ctx Context, img *img48.Img) (*img48.Img, error) {
+	return img, errors.New("failed")
+}
+
+func TestLineError(t *testing.T) {
+	Register(testFail{})
+
+	script := ".inner(\n    test-fail()\n)\n.main(\n    .inner\n)\n"
+	res, err := NewDecoder(strings.NewReader(script), nil, nil).Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	el, _ := res.Get(".main")
+	ctx := NewContext(0, io.Discard, ModeConvert, context.Background())
+	_, err = el.Element.Do(ctx, nil)
+	if err == nil || err.Error() != "line 2: failed" {
+		t.Fatalf("expected the line of the failing element, got: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+
+	"github.com/frizinak/phodo/img48"
+)
+
+// ScaleKey is the context key of the factor by which the images in a
+// pipeline are scaled with respect to their full resolution, e.g.: to render
+// a quick preview. Pixel coordinates and sizes in scripts always refer to
+// the full resolution, elements convert them using ScaledInt and
+// ScaledFloat64.
+const ScaleKey = "pipeline.scale"
+
+// Scale returns the scale of images in ctx, 1 if none was set.
+func Scale(ctx Context) float64 {
+	if s, ok := ctx.Get(ScaleKey).(float64); ok && s > 0 {
+		return s
+	}
+	return 1
+}
+
+// SetScale sets the scale of images in ctx, see ScaleKey.
+func SetScale(ctx Context, scale float64) { ctx.Set(ScaleKey, scale) }
+
+// ScaledFloat64 evaluates the pixel coordinate or size v and scales it to
+// img. Calculations see the full resolution width and height.
+func ScaledFloat64(ctx Context, v Value, img *img48.Img) (float64, error) {
+	s := Scale(ctx)
+	if s == 1 {
+		return v.Float64(img)
+	}
+
+	n, err := v.Float64(fullSize(img, s))
+	return n * s, err
+}
+
+// ScaledInt is ScaledFloat64 for integer values.
+func ScaledInt(ctx Context, v Value, img *img48.Img) (int, error) {
+	s := Scale(ctx)
+	if s == 1 {
+		return v.Int(img)
+	}
+
+	n, err := v.Int(fullSize(img, s))
+	return int(math.Round(float64(n) * s)), err
+}
+
+// FullSize returns img if ctx is not scaled and an image without pixels with
+// the full resolution dimensions of img otherwise. Values that are not pixel
+// coordinates but are stored for later use, e.g.: variables, are evaluated
+// against it so they are always in full resolution units.
+func FullSize(ctx Context, img *img48.Img) *img48.Img {
+	s := Scale(ctx)
+	if s == 1 {
+		return img
+	}
+	return fullSize(img, s)
+}
+
+// fullSize returns an image without pixels with the estimated dimensions of
+// the full resolution version of img.
+func fullSize(img *img48.Img, s float64) *img48.Img {
+	if img == nil {
+		return nil
+	}
+	return &img48.Img{
+		Rect: image.Rect(
+			0,
+			0,
+			int(math.Round(float64(img.Rect.Dx())/s)),
+			int(math.Round(float64(img.Rect.Dy())/s)),
+		),
+	}
+}